@@ -1,11 +1,20 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
 
 	"github.com/hallucinaut/privacyguard/pkg/scan"
 	"github.com/hallucinaut/privacyguard/pkg/compliance"
+	"github.com/hallucinaut/privacyguard/pkg/engine"
+	"github.com/hallucinaut/privacyguard/pkg/walk"
 )
 
 const version = "1.0.0"
@@ -23,7 +32,7 @@ func main() {
 			printUsage()
 			return
 		}
-		scanPrivacy(os.Args[2])
+		scanPrivacy(os.Args[2:])
 	case "compliance":
 		if len(os.Args) < 3 {
 			fmt.Println("Error: regulation required")
@@ -59,52 +68,240 @@ Commands:
   version            Show version information
   help               Show this help message
 
+Scan Options:
+  --include <glob>   Only scan files matching glob (repeatable)
+  --exclude <glob>   Skip files and directories matching glob (repeatable)
+  --follow-symlinks  Follow symbolic links
+  --hidden           Scan hidden files and directories
+  --max-size <size>  Skip files larger than size (e.g. 512K, 10M; 0 = no limit)
+  --workers <n>      Number of concurrent scan workers (default: CPU count)
+  --chunk-size <size> Bytes scanned at a time from large files (default 4M)
+  --progress         Report progress on stderr
+  --patterns <file>  Load detection patterns from a YAML pattern pack (repeatable)
+  --min-confidence <n> Only report findings with at least this confidence (default 0.3)
+  --decode-depth <n> Nested encodings (base64, hex, URL...) to unwrap (default 2; 0 = off)
+  --hashes           Report MD5/SHA-1/SHA-256 digests of PII in fields such as email_sha256
+  --known-values <file> Report digests of the values in file, one per line (implies --hashes)
+  --collapse         Report each distinct value once, with its number of occurrences
+  --binary           Scan the printable text of binary files instead of skipping them
+  --max-document-size <size> Skip JSON, YAML, Office and PDF files larger than size (default 64M)
+  --archive-depth <n> Levels of nested zip, tar and compressed files to open (default 3; 0 = off)
+  --archive-max-size <size> Uncompressed bytes read from each archive (default 1G)
+  --archive-max-entries <n> Files visited in each archive (default 10000)
+
 Examples:
   privacyguard scan /path/to/code
+  privacyguard scan /path/to/code --include '*.go' --exclude vendor
   privacyguard compliance GDPR
   privacyguard check
 `)
 }
 
-func scanPrivacy(path string) {
-	fmt.Printf("Scanning for PII: %s\n", path)
-	fmt.Println()
+func scanPrivacy(args []string) {
+	var includes, excludes, patternFiles stringList
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	fs.Var(&includes, "include", "only scan files matching glob")
+	fs.Var(&excludes, "exclude", "skip files and directories matching glob")
+	followSymlinks := fs.Bool("follow-symlinks", false, "follow symbolic links")
+	hidden := fs.Bool("hidden", false, "scan hidden files and directories")
+	maxSize := fs.String("max-size", "10M", "skip files larger than size")
+	workers := fs.Int("workers", runtime.NumCPU(), "number of concurrent scan workers")
+	chunkSize := fs.String("chunk-size", "4M", "bytes scanned per chunk of a large file")
+	showProgress := fs.Bool("progress", false, "report progress on stderr")
+	fs.Var(&patternFiles, "patterns", "load detection patterns from a YAML pattern pack")
+	minConfidence := fs.Float64("min-confidence", 0.3, "only report findings with at least this confidence")
+	decodeDepth := fs.Int("decode-depth", scan.DefaultDecodeDepth, "nested encodings to unwrap before scanning")
+	hashes := fs.Bool("hashes", false, "report digests of PII")
+	knownValuesFile := fs.String("known-values", "", "report digests of the values in file")
+	collapse := fs.Bool("collapse", false, "report each distinct value once")
+	scanBinary := fs.Bool("binary", false, "scan the printable text of binary files")
+	maxDocumentSize := fs.String("max-document-size", "64M", "skip documents parsed whole that are larger than size")
+	archiveDepth := fs.Int("archive-depth", walk.DefaultArchiveDepth, "levels of nested archives to open")
+	archiveMaxSize := fs.String("archive-max-size", "1G", "uncompressed bytes read from each archive")
+	archiveMaxEntries := fs.Int("archive-max-entries", walk.DefaultArchiveEntries, "files visited in each archive")
+
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(2)
+	}
+	if len(paths) != 1 {
+		fmt.Println("Error: exactly one file/directory required")
+		printUsage()
+		os.Exit(2)
+	}
+	path := paths[0]
+
+	maxFileSize, err := parseSize(*maxSize)
+	if err != nil {
+		fmt.Printf("Error: invalid --max-size: %v\n", err)
+		os.Exit(2)
+	}
+	chunkBytes, err := parseSize(*chunkSize)
+	if err != nil || chunkBytes == 0 {
+		fmt.Printf("Error: invalid --chunk-size: %s\n", *chunkSize)
+		os.Exit(2)
+	}
+	documentBytes, err := parseSize(*maxDocumentSize)
+	if err != nil || documentBytes == 0 {
+		fmt.Printf("Error: invalid --max-document-size: %s\n", *maxDocumentSize)
+		os.Exit(2)
+	}
+	archiveBytes, err := parseSize(*archiveMaxSize)
+	if err != nil || archiveBytes == 0 {
+		fmt.Printf("Error: invalid --archive-max-size: %s\n", *archiveMaxSize)
+		os.Exit(2)
+	}
+	if *archiveDepth == 0 {
+		*archiveDepth = -1
+	}
+	if err := walk.ValidatePatterns(append(includes, excludes...)); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	options := scan.Options{
+		MinConfidence:      *minConfidence,
+		DecodeDepth:        *decodeDepth,
+		HashDetection:      *hashes,
+		CollapseDuplicates: *collapse,
+	}
+	if *decodeDepth == 0 {
+		options.DecodeDepth = -1
+	}
+	for _, file := range patternFiles {
+		pack, err := scan.LoadPatternPack(file)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(2)
+		}
+		options.Packs = append(options.Packs, pack)
+	}
+	if *knownValuesFile != "" {
+		data, err := os.ReadFile(*knownValuesFile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(2)
+		}
+		options.KnownValues = strings.Split(string(data), "\n")
+	}
+
+	scanner, err := scan.New(options)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(2)
+	}
 
-	// In production: scan files/directories
-	// For demo: show scanning capabilities
-	fmt.Println("PII Detection:")
-	fmt.Println("  ✓ Email addresses")
-	fmt.Println("  ✓ Phone numbers")
-	fmt.Println("  ✓ Social Security Numbers")
-	fmt.Println("  ✓ Credit card numbers")
-	fmt.Println("  ✓ Bank account numbers")
-	fmt.Println("  ✓ IP addresses")
-	fmt.Println("  ✓ Medical records")
-	fmt.Println("  ✓ Date of birth")
+	fmt.Printf("Scanning for PII: %s\n", path)
 	fmt.Println()
 
-	// Example scanning
-	_ = scan.NewScanner()
-	result := &scan.ScanResult{
-		TotalFound: 25,
-		Summary: map[string]int{
-			"email":         10,
-			"phone":         5,
-			"credit_card":   2,
-			"ip_address":    8,
-			"ssn":           0,
+	var progress engine.Progress
+	e := engine.New(engine.Options{
+		Workers:         *workers,
+		ChunkSize:       int(chunkBytes),
+		Scanner:         scanner,
+		ScanBinary:      *scanBinary,
+		MaxDocumentSize: documentBytes,
+		Walk: walk.Options{
+			Include:        includes,
+			Exclude:        excludes,
+			FollowSymlinks: *followSymlinks,
+			MaxFileSize:    maxFileSize,
+			IncludeHidden:  *hidden,
+			ArchiveDepth:   *archiveDepth,
+			ArchiveSize:    archiveBytes,
+			ArchiveEntries: *archiveMaxEntries,
+			OnSkip: func(path, reason string) {
+				if reason == walk.SkipUnreadableDir {
+					fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", path, reason)
+				}
+			},
+		},
+		Progress: func(p engine.Progress) {
+			progress = p
+			if *showProgress {
+				fmt.Fprintf(os.Stderr, "\r%d/%d files, %d bytes, %d findings", p.FilesScanned, p.FilesQueued, p.BytesScanned, p.Findings)
+			}
 		},
-		Compliance: map[string]string{
-			"GDPR":      "AT_RISK",
-			"HIPAA":     "REVIEW",
-			"CCPA":      "AT_RISK",
-			"PCI-DSS":   "NON_COMPLIANT",
+		OnError: func(path string, err error) {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 		},
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	result, err := e.Run(ctx, path)
+	if *showProgress {
+		fmt.Fprintln(os.Stderr)
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Println("Scan interrupted; showing partial results")
+		fmt.Println()
 	}
 
+	fmt.Printf("Files scanned: %d\n\n", progress.FilesScanned)
 	fmt.Println(scan.GenerateReport(result))
 }
 
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// parseInterspersed parses flags that may appear before or after positional
+// arguments and returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := make([]string, 0)
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// parseSize parses a byte size such as "512", "64K", "10M" or "1G".
+func parseSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	value = strings.TrimSuffix(value, "B")
+
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(value, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(value, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(value, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		value = value[:len(value)-1]
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("bad size %q", value)
+	}
+	return n * multiplier, nil
+}
+
 func checkCompliance(regulation string) {
 	fmt.Printf("Checking compliance: %s\n", regulation)
 	fmt.Println()
@@ -2,4 +2,7 @@ module github.com/hallucinaut/privacyguard
 
 go 1.21
 
-require gopkg.in/yaml.v3 v3.0.1
\ No newline at end of file
+require (
+	github.com/ulikunitz/xz v0.5.15
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -17,8 +17,22 @@ const (
 	RegulationPCI_DSS  Regulation = "PCI-DSS"
 	RegulationPIPEDA   Regulation = "PIPEDA"
 	RegulationLGPD     Regulation = "LGPD"
+	RegulationDPDP     Regulation = "DPDP"
 )
 
+// healthData lists the scan summary keys that count as protected health
+// information.
+var healthData = []string{"medical", "medical_record", "diagnosis_code", "procedure_code", "health_plan_id", "biometric"}
+
+// nationalIDs lists the scan summary keys of national identifiers governed
+// by each regulation.
+var nationalIDs = map[Regulation][]string{
+	RegulationGDPR:   {"uk_nino", "de_steuer_id", "fr_nir", "es_dni", "es_nie", "nl_bsn"},
+	RegulationPIPEDA: {"ca_sin"},
+	RegulationLGPD:   {"br_cpf", "br_cnpj"},
+	RegulationDPDP:   {"in_aadhaar", "in_pan"},
+}
+
 // ComplianceRequirement represents a compliance requirement.
 type ComplianceRequirement struct {
 	Regulation  Regulation
@@ -78,6 +92,22 @@ func (c *ComplianceChecker) InitializeRequirements() {
 			Requirement: "Individuals must be able to access, correct, delete their data",
 			Scope:       "processing",
 		},
+		{
+			Regulation:  RegulationGDPR,
+			ID:          "GDPR-004",
+			Name:        "National Identification Numbers",
+			Description: "Protect national identification numbers",
+			Requirement: "National identifiers may only be processed under specific safeguards",
+			Scope:       "eu",
+		},
+		{
+			Regulation:  RegulationGDPR,
+			ID:          "GDPR-005",
+			Name:        "Pseudonymized Data",
+			Description: "Treat hashed identifiers as personal data",
+			Requirement: "Pseudonymized data that can be attributed to a person remains personal data",
+			Scope:       "all",
+		},
 		{
 			Regulation:  RegulationHIPAA,
 			ID:          "HIPAA-001",
@@ -110,6 +140,30 @@ func (c *ComplianceChecker) InitializeRequirements() {
 			Requirement: "Cardholder data must be encrypted at rest and in transit",
 			Scope:       "payment",
 		},
+		{
+			Regulation:  RegulationPIPEDA,
+			ID:          "PIPEDA-001",
+			Name:        "Social Insurance Numbers",
+			Description: "Limit use of Social Insurance Numbers",
+			Requirement: "SINs must not be collected as general-purpose identifiers",
+			Scope:       "canada",
+		},
+		{
+			Regulation:  RegulationLGPD,
+			ID:          "LGPD-001",
+			Name:        "CPF and CNPJ Numbers",
+			Description: "Protect Brazilian taxpayer numbers",
+			Requirement: "CPF and CNPJ numbers must be processed on a legal basis and secured",
+			Scope:       "brazil",
+		},
+		{
+			Regulation:  RegulationDPDP,
+			ID:          "DPDP-001",
+			Name:        "Aadhaar and PAN Numbers",
+			Description: "Protect Indian identity numbers",
+			Requirement: "Aadhaar numbers must be masked or stored in a vault",
+			Scope:       "india",
+		},
 	}
 }
 
@@ -161,6 +215,8 @@ func (c *ComplianceChecker) evaluateRequirement(req ComplianceRequirement, piiDa
 		return c.evaluateCCPA(req, piiData)
 	case RegulationPCI_DSS:
 		return c.evaluatePCIDSS(req, piiData)
+	case RegulationPIPEDA, RegulationLGPD, RegulationDPDP:
+		return c.evaluateNationalIDs(req, piiData)
 	}
 
 	return nil
@@ -189,6 +245,15 @@ func (c *ComplianceChecker) evaluateGDPR(req ComplianceRequirement, piiData map[
 			Issue:         "Data subject rights implementation required",
 			Recommendation: "Implement data access, deletion, and correction mechanisms",
 		}
+	case "GDPR-004":
+		return c.evaluateNationalIDs(req, piiData)
+	case "GDPR-005":
+		if piiData["pseudonymized"] > 0 {
+			return &ComplianceIssue{
+				Issue:         fmt.Sprintf("%d hashed identifier(s) detected", piiData["pseudonymized"]),
+				Recommendation: "Salt or key hashes of identifiers and keep them under the same controls as the original data",
+			}
+		}
 	}
 
 	return nil
@@ -198,7 +263,11 @@ func (c *ComplianceChecker) evaluateGDPR(req ComplianceRequirement, piiData map[
 func (c *ComplianceChecker) evaluateHIPAA(req ComplianceRequirement, piiData map[string]int) *ComplianceIssue {
 	switch req.ID {
 	case "HIPAA-001":
-		if _, exists := piiData["medical"]; exists && piiData["medical"] > 0 {
+		phi := 0
+		for _, key := range healthData {
+			phi += piiData[key]
+		}
+		if phi > 0 {
 			return &ComplianceIssue{
 				Issue:         "Protected Health Information detected",
 				Recommendation: "Ensure PHI is encrypted and access controlled",
@@ -244,6 +313,23 @@ func (c *ComplianceChecker) evaluatePCIDSS(req ComplianceRequirement, piiData ma
 	return nil
 }
 
+// evaluateNationalIDs evaluates requirements on national identifiers.
+func (c *ComplianceChecker) evaluateNationalIDs(req ComplianceRequirement, piiData map[string]int) *ComplianceIssue {
+	count := 0
+	for _, key := range nationalIDs[req.Regulation] {
+		count += piiData[key]
+	}
+
+	if count > 0 {
+		return &ComplianceIssue{
+			Issue:         req.Name + " detected",
+			Recommendation: req.Requirement,
+		}
+	}
+
+	return nil
+}
+
 // calculateComplianceScore calculates compliance score.
 func (c *ComplianceChecker) calculateComplianceScore(status *ComplianceStatus) float64 {
 	totalRequirements := 8 // Approximate number of requirements checked
@@ -313,7 +399,7 @@ func CheckAllRegulations(piiData map[string]int) map[Regulation]*ComplianceStatu
 	
 	results := make(map[Regulation]*ComplianceStatus)
 	
-	for _, reg := range []Regulation{RegulationGDPR, RegulationHIPAA, RegulationCCPA, RegulationPCI_DSS} {
+	for _, reg := range []Regulation{RegulationGDPR, RegulationHIPAA, RegulationCCPA, RegulationPCI_DSS, RegulationPIPEDA, RegulationLGPD, RegulationDPDP} {
 		results[reg] = checker.CheckCompliance(reg, piiData)
 	}
 	
@@ -0,0 +1,548 @@
+// Package engine provides a concurrent file scanning engine.
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/hallucinaut/privacyguard/pkg/extract"
+	"github.com/hallucinaut/privacyguard/pkg/scan"
+	"github.com/hallucinaut/privacyguard/pkg/walk"
+)
+
+// Default engine settings.
+const (
+	DefaultChunkSize       = 4 << 20
+	DefaultOverlap         = 4 << 10
+	DefaultMaxDocumentSize = 64 << 20
+)
+
+// errDocumentTooLarge is returned when a file parsed whole is larger than
+// MaxDocumentSize.
+var errDocumentTooLarge = errors.New("document too large to parse")
+
+// Options configures a scan engine.
+type Options struct {
+	Workers   int // Number of concurrent workers; defaults to the number of CPUs
+	ChunkSize int // Bytes scanned per chunk when streaming a file
+	Overlap   int // Bytes shared by adjacent chunks; bounds the longest match found across a chunk boundary
+
+	// Walk selects the files to scan.
+	Walk walk.Options
+
+	// ScanBinary scans the printable text of binary files. By default they
+	// are listed in the result as skipped.
+	ScanBinary bool
+
+	// MaxDocumentSize bounds the files that are read whole to be parsed:
+	// JSON, NDJSON and YAML documents, Office and PDF files. Larger ones are
+	// listed in the result as skipped. Defaults to DefaultMaxDocumentSize.
+	MaxDocumentSize int64
+
+	// Scanner is shared by all workers. Defaults to scan.NewScanner().
+	Scanner *scan.Scanner
+
+	// Progress, when set, is called after each chunk and each file. Calls are
+	// serialized.
+	Progress func(Progress)
+
+	// OnError, when set, is called for files that could not be read. Such
+	// files are skipped and the scan continues.
+	OnError func(path string, err error)
+}
+
+// Progress reports how far a scan has got.
+type Progress struct {
+	FilesQueued  int    // Files selected by the walker so far
+	FilesScanned int    // Files completely scanned
+	BytesScanned int64  // Bytes read across all files
+	Findings     int    // PII records found so far
+	Path         string // File that produced this update
+}
+
+// Engine fans files out to a pool of workers and merges their results.
+type Engine struct {
+	options Options
+	walker  *walk.Walker
+
+	mu       sync.Mutex
+	progress Progress
+}
+
+// job is a file queued for scanning; index orders the merged results.
+type job struct {
+	index int
+	file  walk.File
+}
+
+// New creates a new scan engine.
+func New(options Options) *Engine {
+	if options.Workers <= 0 {
+		options.Workers = runtime.NumCPU()
+	}
+	if options.ChunkSize <= 0 {
+		options.ChunkSize = DefaultChunkSize
+	}
+	if options.Overlap <= 0 {
+		options.Overlap = DefaultOverlap
+	}
+	if options.MaxDocumentSize <= 0 {
+		options.MaxDocumentSize = DefaultMaxDocumentSize
+	}
+	if options.Scanner == nil {
+		options.Scanner = scan.NewScanner()
+	}
+
+	return &Engine{options: options, walker: walk.NewWalker(options.Walk)}
+}
+
+// Run scans every file selected below root. Results are merged in walk order,
+// so the same tree always produces the same report regardless of scheduling.
+// If ctx is cancelled, Run stops early and returns the findings gathered so
+// far together with the context's error.
+func (e *Engine) Run(ctx context.Context, root string) (*scan.ScanResult, error) {
+	e.mu.Lock()
+	e.progress = Progress{}
+	e.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan job, e.options.Workers)
+	var results []*scan.ScanResult
+	var resultsMu sync.Mutex
+
+	var wg sync.WaitGroup
+	for i := 0; i < e.options.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				result, err := e.scanFile(ctx, j.file)
+				if err != nil && ctx.Err() == nil && e.options.OnError != nil {
+					e.options.OnError(j.file.Path, err)
+				}
+
+				resultsMu.Lock()
+				results[j.index] = result
+				resultsMu.Unlock()
+			}
+		}()
+	}
+
+	walkErr := e.walker.Walk(root, func(file walk.File) error {
+		resultsMu.Lock()
+		index := len(results)
+		results = append(results, nil)
+		resultsMu.Unlock()
+
+		e.update(func(p *Progress) {
+			p.FilesQueued++
+			p.Path = file.Path
+		})
+
+		select {
+		case jobs <- job{index: index, file: file}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(jobs)
+	wg.Wait()
+
+	merged := e.options.Scanner.Merge(results...)
+
+	if err := ctx.Err(); err != nil {
+		return merged, err
+	}
+	if walkErr != nil && !errors.Is(walkErr, context.Canceled) {
+		return merged, walkErr
+	}
+	return merged, nil
+}
+
+// scanFile scans a file found by the walker.
+func (e *Engine) scanFile(ctx context.Context, file walk.File) (*scan.ScanResult, error) {
+	f, err := os.Open(file.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	result, err := e.scanContent(ctx, file, f)
+	e.update(func(p *Progress) {
+		p.FilesScanned++
+		p.Path = file.Path
+	})
+	return result, err
+}
+
+// scanContent scans the content of a file, streaming it in chunks. Text is
+// transcoded to UTF-8; PDF and Office documents have their text extracted;
+// archives are opened and their files scanned in turn; other binary files
+// are skipped or reduced to their printable text.
+func (e *Engine) scanContent(ctx context.Context, file walk.File, content io.Reader) (*scan.ScanResult, error) {
+	info := scan.FileInfo{
+		Location: file.Path,
+		Type:     file.Type,
+		Encoding: file.Encoding,
+		BOM:      file.BOM,
+	}
+
+	// A file the walker could not sniff is read as text
+	binary := file.Type != "" && file.Type != walk.TypeText
+	structure := extract.Structure(file.Path)
+	switch {
+	case file.Type == walk.TypePDF:
+		// Recognized by content, whatever the name
+		structure = extract.FormatPDF
+	case structure == extract.FormatOffice && file.Type == walk.TypeZip:
+	case binary || structure == extract.FormatOffice || structure == extract.FormatPDF:
+		structure = ""
+	}
+	archive := structure == "" && e.walker.IsArchive(file)
+	whole := structure != "" && structure != extract.FormatCSV && structure != extract.FormatTSV
+
+	skip := func(reason string) *scan.ScanResult {
+		info.Skipped, info.SkipReason = true, reason
+		result := e.options.Scanner.Merge()
+		result.Files = []scan.FileInfo{info}
+		return result
+	}
+	if binary && structure == "" && !archive && !e.options.ScanBinary {
+		return skip(scan.SkipBinary), nil
+	}
+	if whole && file.Size > e.options.MaxDocumentSize {
+		return skip(scan.SkipTooLarge), nil
+	}
+
+	r := walk.NewTextReader(content, file.Format)
+	if binary {
+		r = printableReader{content}
+	}
+
+	results := make([]*scan.ScanResult, 0)
+	emit := func(result *scan.ScanResult, n int) {
+		results = append(results, result)
+		e.update(func(p *Progress) {
+			p.BytesScanned += int64(n)
+			p.Findings += result.TotalFound
+			p.Path = file.Path
+		})
+	}
+	var err error
+	switch {
+	case archive:
+		var entries []*scan.ScanResult
+		entries, err = e.scanArchive(ctx, file, content)
+		results = append(results, entries...)
+	case structure == extract.FormatCSV:
+		err = e.scanTable(ctx, r, file.Path, ',', emit)
+	case structure == extract.FormatTSV:
+		err = e.scanTable(ctx, r, file.Path, '\t', emit)
+	case structure == extract.FormatJSON, structure == extract.FormatNDJSON, structure == extract.FormatYAML:
+		err = e.scanDocument(ctx, r, file.Path, structure, emit)
+	case structure == extract.FormatOffice:
+		var kind string
+		if kind, err = e.scanOffice(ctx, content, file.Size, file.Path, emit); kind != "" {
+			info.Type = kind
+		}
+	case structure == extract.FormatPDF:
+		err = e.scanPDF(ctx, content, file.Path, emit)
+	default:
+		err = e.scanStream(ctx, r, file.Path, emit)
+	}
+
+	// A document of unknown size is only found to be too large once read
+	if errors.Is(err, errDocumentTooLarge) {
+		return skip(scan.SkipTooLarge), nil
+	}
+
+	result := e.options.Scanner.Merge(results...)
+	result.Files = append([]scan.FileInfo{info}, result.Files...)
+	return result, err
+}
+
+// readDocument reads a file that is parsed whole, failing with
+// errDocumentTooLarge once more than MaxDocumentSize bytes are read.
+func (e *Engine) readDocument(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, e.options.MaxDocumentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > e.options.MaxDocumentSize {
+		return nil, errDocumentTooLarge
+	}
+	return data, nil
+}
+
+// scanArchive scans the files in an archive, opening nested archives in
+// turn. A file that cannot be scanned is reported through OnError and the
+// rest of the archive is still scanned, unless the archive's limits are
+// exceeded.
+func (e *Engine) scanArchive(ctx context.Context, archive walk.File, r io.Reader) ([]*scan.ScanResult, error) {
+	results := make([]*scan.ScanResult, 0)
+	err := e.walker.WalkArchive(archive, r, func(file walk.File, r io.Reader) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		result, err := e.scanContent(ctx, file, r)
+		if result != nil {
+			results = append(results, result)
+		}
+		if err != nil && ctx.Err() == nil && !errors.Is(err, walk.ErrArchiveLimit) {
+			if e.options.OnError != nil {
+				e.options.OnError(file.Path, err)
+			}
+			return nil
+		}
+		return err
+	})
+	return results, err
+}
+
+// printableReader reads the printable ASCII text of binary data, replacing
+// every other byte with a newline so that offsets match the data.
+type printableReader struct {
+	r io.Reader
+}
+
+func (p printableReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	for i, c := range b[:n] {
+		if (c < 0x20 && c != '\t') || c >= 0x7f {
+			b[i] = '\n'
+		}
+	}
+	return n, err
+}
+
+// tableBatch is the number of rows of a table, or values of a document,
+// scanned between checks for cancellation and progress reports.
+const tableBatch = 1000
+
+// scanTable scans the cells of a CSV or TSV table one by one, each with its
+// column header as context, and classifies the table's columns. Results are
+// emitted every tableBatch rows, the last of them carrying the column
+// summaries. A row with a cell longer than ChunkSize, as left by an unclosed
+// quote, is reported through OnError and skipped.
+func (e *Engine) scanTable(ctx context.Context, r io.Reader, location string, comma byte, emit func(*scan.ScanResult, int)) error {
+	reader := extract.NewCSVReader(r, comma)
+	reader.MaxCellSize = e.options.ChunkSize
+	var table *scan.Table
+
+	batch := make([]*scan.ScanResult, 0)
+	var reported int64
+	flush := func(last bool) {
+		result := e.options.Scanner.Merge(batch...)
+		if last && table != nil {
+			result.Columns = table.Columns()
+		}
+		emit(result, int(reader.Offset()-reported))
+		reported = reader.Offset()
+		batch = batch[:0]
+	}
+
+	for rows := 1; ; rows++ {
+		if err := ctx.Err(); err != nil {
+			flush(true)
+			return err
+		}
+
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if errors.Is(err, extract.ErrMalformedRow) {
+			if e.options.OnError != nil {
+				e.options.OnError(location, fmt.Errorf("%s: %w", location, err))
+			}
+			continue
+		} else if err != nil {
+			// Keep the findings and column summary of the rows read so far
+			flush(true)
+			return err
+		}
+		if table == nil {
+			table = scan.NewTable(location, reader.Header())
+		}
+
+		for i, value := range row {
+			result := e.options.Scanner.ScanValue(value, location)
+			table.Add(i, value.Text, result)
+			if len(result.PIIRecords) > 0 || result.Rejected > 0 || result.LowConfidence > 0 || result.Suppressed > 0 {
+				batch = append(batch, result)
+			}
+		}
+
+		if rows%tableBatch == 0 {
+			flush(false)
+		}
+	}
+
+	flush(true)
+	return nil
+}
+
+// scanDocument scans the values of a JSON, NDJSON or YAML document one by
+// one, each named by its JSON path. Documents are read whole; one that is
+// not well formed is scanned as plain text instead.
+func (e *Engine) scanDocument(ctx context.Context, r io.Reader, location, format string, emit func(*scan.ScanResult, int)) error {
+	data, err := e.readDocument(r)
+	if err != nil {
+		return err
+	}
+	content := string(data)
+
+	var values []scan.Value
+	if format == extract.FormatYAML {
+		values, err = extract.YAML(content)
+	} else {
+		values, err = extract.JSON(content, format == extract.FormatNDJSON)
+	}
+	if err != nil {
+		return e.scanStream(ctx, strings.NewReader(content), location, emit)
+	}
+
+	emit(e.scanValues(ctx, values, location), len(data))
+	return ctx.Err()
+}
+
+// scanOffice scans the text, cells, comments and metadata of an Office or
+// OpenDocument file, each named by where it lies in the document. It returns
+// the kind of document, such as "docx". The file is read in place if r is an
+// io.ReaderAt of the given size, and otherwise loaded into memory.
+func (e *Engine) scanOffice(ctx context.Context, r io.Reader, size int64, location string, emit func(*scan.ScanResult, int)) (string, error) {
+	ra, ok := r.(io.ReaderAt)
+	if !ok || size < 0 {
+		data, err := e.readDocument(r)
+		if err != nil {
+			return "", err
+		}
+		ra, size = bytes.NewReader(data), int64(len(data))
+	}
+	kind, values, err := extract.Office(ra, size)
+	if err != nil {
+		return kind, fmt.Errorf("%s: %w", location, err)
+	}
+
+	emit(e.scanValues(ctx, values, location), int(size))
+	return kind, ctx.Err()
+}
+
+// scanPDF scans the text of each page of a PDF file and its metadata,
+// reading the file whole.
+func (e *Engine) scanPDF(ctx context.Context, r io.Reader, location string, emit func(*scan.ScanResult, int)) error {
+	data, err := e.readDocument(r)
+	if err != nil {
+		return err
+	}
+	values, err := extract.PDF(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", location, err)
+	}
+
+	emit(e.scanValues(ctx, values, location), len(data))
+	return ctx.Err()
+}
+
+// scanValues scans extracted values one by one, stopping early if ctx is
+// cancelled.
+func (e *Engine) scanValues(ctx context.Context, values []scan.Value, location string) *scan.ScanResult {
+	results := make([]*scan.ScanResult, 0)
+	for i, value := range values {
+		if i%tableBatch == 0 && ctx.Err() != nil {
+			break
+		}
+		result := e.options.Scanner.ScanValue(value, location)
+		if len(result.PIIRecords) > 0 || result.Rejected > 0 || result.LowConfidence > 0 || result.Suppressed > 0 {
+			results = append(results, result)
+		}
+	}
+	return e.options.Scanner.Merge(results...)
+}
+
+// scanStream reads r in chunks of ChunkSize bytes. Each chunk is scanned
+// together with up to Overlap bytes on either side, but only matches starting
+// inside the chunk itself are kept: a match straddling a boundary is found
+// whole in the window of the chunk it starts in, and is not repeated by the
+// next one.
+func (e *Engine) scanStream(ctx context.Context, r io.Reader, location string, emit func(*scan.ScanResult, int)) error {
+	chunkSize, overlap := e.options.ChunkSize, e.options.Overlap
+
+	// buf holds [lead | chunk | lookahead]; lead is the tail of the previous
+	// chunk and is empty for the first one.
+	buf := make([]byte, 0, overlap+chunkSize+overlap)
+	lead := 0
+	eof := false
+
+	// Position of buf[0] within the input
+	var offset int64
+	line, column := 1, 1
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Fill the buffer up to a full window or the end of the input
+		for !eof && len(buf) < lead+chunkSize+overlap {
+			n, err := r.Read(buf[len(buf):cap(buf)])
+			buf = buf[:len(buf)+n]
+			if err == io.EOF {
+				eof = true
+			} else if err != nil {
+				return err
+			}
+		}
+
+		end := min(lead+chunkSize, len(buf))
+		if end <= lead {
+			return nil
+		}
+
+		result := e.options.Scanner.ScanRange(string(buf), location, lead, end)
+		result.Offset(offset, line, column)
+		emit(result, end-lead)
+
+		if eof && end == len(buf) {
+			return nil
+		}
+
+		// Keep the last overlap bytes of this chunk as the next chunk's lead
+		keep := max(end-overlap, 0)
+		line, column = advance(buf[:keep], line, column)
+		offset += int64(keep)
+		lead = end - keep
+		buf = buf[:copy(buf, buf[keep:])]
+	}
+}
+
+// advance returns the line and column reached after reading data from the
+// given position.
+func advance(data []byte, line, column int) (int, int) {
+	if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
+		line += bytes.Count(data, []byte{'\n'})
+		column = 1
+		data = data[i+1:]
+	}
+	return line, column + scan.CountChars(string(data))
+}
+
+// update applies fn to the progress counters and reports the new state.
+func (e *Engine) update(fn func(*Progress)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	fn(&e.progress)
+	if e.options.Progress != nil {
+		e.options.Progress(e.progress)
+	}
+}
@@ -0,0 +1,383 @@
+package engine
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/hallucinaut/privacyguard/pkg/scan"
+)
+
+func TestRunChunkBoundaries(t *testing.T) {
+	var content strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&content, "row %d – user%d@example.com 10.0.%d.%d\n", i, i, i%250, i%7)
+	}
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "data.txt"), []byte(content.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	want := scan.NewScanner().Scan(content.String(), "data.txt")
+
+	for _, chunkSize := range []int{17, 64, 1000, 1 << 20} {
+		e := New(Options{Workers: 4, ChunkSize: chunkSize, Overlap: 48})
+		got, err := e.Run(context.Background(), root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.TotalFound != want.TotalFound {
+			t.Errorf("chunk size %d: found %d, want %d", chunkSize, got.TotalFound, want.TotalFound)
+		}
+		for i, record := range got.PIIRecords {
+			w := want.PIIRecords[i]
+			if record.Value != w.Value || record.Start != w.Start || record.Line != w.Line || record.Column != w.Column {
+				t.Errorf("chunk size %d: record %d = %q at %d (%d:%d), want %q at %d (%d:%d)",
+					chunkSize, i, record.Value, record.Start, record.Line, record.Column, w.Value, w.Start, w.Line, w.Column)
+				break
+			}
+		}
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("a@example.com"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := New(Options{}).Run(ctx, root); err != context.Canceled {
+		t.Errorf("Run with cancelled context returned %v, want context.Canceled", err)
+	}
+}
+
+func TestRunEncodings(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"utf8.txt":    "\xef\xbb\xbfcontact: ana@example.com\n",
+		"utf16le.txt": "\xff\xfec\x00o\x00n\x00t\x00a\x00c\x00t\x00:\x00 \x00b\x00o\x00@\x00e\x00x\x00a\x00m\x00p\x00l\x00e\x00.\x00c\x00o\x00m\x00\n\x00",
+		"latin1.txt":  "Jos\xe9: jose@example.com\n",
+		"data.bin":    "\x00\x01\x02\x03 carl@example.com \x00\xff",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, scanBinary := range []bool{false, true} {
+		result, err := New(Options{Workers: 2, ScanBinary: scanBinary}).Run(context.Background(), root)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		found := make(map[string]string)
+		for _, record := range result.PIIRecords {
+			found[filepath.Base(record.Location)] = record.Value
+		}
+		want := map[string]string{
+			"utf8.txt":    "ana@example.com",
+			"utf16le.txt": "bo@example.com",
+			"latin1.txt":  "jose@example.com",
+		}
+		if scanBinary {
+			want["data.bin"] = "carl@example.com"
+		}
+		for name, value := range want {
+			if found[name] != value {
+				t.Errorf("scan binary %v: %s: found %q, want %q", scanBinary, name, found[name], value)
+			}
+		}
+		if len(found) != len(want) {
+			t.Errorf("scan binary %v: found %v, want %v", scanBinary, found, want)
+		}
+
+		formats := make(map[string]scan.FileInfo)
+		for _, file := range result.Files {
+			formats[filepath.Base(file.Location)] = file
+		}
+		wantFormats := map[string]scan.FileInfo{
+			"utf8.txt":    {Type: "text", Encoding: "utf-8", BOM: true},
+			"utf16le.txt": {Type: "text", Encoding: "utf-16le", BOM: true},
+			"latin1.txt":  {Type: "text", Encoding: "latin-1"},
+			"data.bin":    {Type: "binary"},
+		}
+		if !scanBinary {
+			wantFormats["data.bin"] = scan.FileInfo{Type: "binary", Skipped: true, SkipReason: scan.SkipBinary}
+		}
+		for name, want := range wantFormats {
+			got := formats[name]
+			got.Location = ""
+			if got != want {
+				t.Errorf("scan binary %v: %s: file info %+v, want %+v", scanBinary, name, got, want)
+			}
+		}
+	}
+}
+
+func TestRunTable(t *testing.T) {
+	var content strings.Builder
+	content.WriteString("id,name,contact,dob\n")
+	for i := 0; i < 2500; i++ {
+		fmt.Fprintf(&content, "%d,Customer %d,user%d@example.com,1980-01-%02d\n", i, i, i, i%28+1)
+	}
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "export.csv"), []byte(content.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := New(Options{}).Run(context.Background(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Summary["email"] != 2500 {
+		t.Errorf("found %d emails, want 2500", result.Summary["email"])
+	}
+
+	last := result.PIIRecords[len(result.PIIRecords)-1]
+	if last.Row != 2500 || last.Field != "contact" || last.Line != 2501 || last.Column != 20 {
+		t.Errorf("last record at row %d %s, %d:%d, want row 2500 contact, 2501:20", last.Row, last.Field, last.Line, last.Column)
+	}
+	if got := content.String()[last.Start:last.End]; got != last.Value {
+		t.Errorf("last record spans %q, want %q", got, last.Value)
+	}
+
+	if len(result.Columns) != 4 {
+		t.Fatalf("got %d column summaries, want 4", len(result.Columns))
+	}
+	for i, want := range []scan.PIIType{"", scan.TypeName, scan.TypeEmail, scan.TypeDateOfBirth} {
+		if column := result.Columns[i]; column.Type != want {
+			t.Errorf("column %s classified %q (%s), want %q", column.Name, column.Type, column.Reason, want)
+		}
+	}
+}
+
+func TestScanTableReadError(t *testing.T) {
+	failure := errors.New("disk error")
+	r := io.MultiReader(strings.NewReader("name,contact\nAda,ada@example.com\n"), iotest.ErrReader(failure))
+
+	var results []*scan.ScanResult
+	err := New(Options{}).scanTable(context.Background(), r, "export.csv", ',', func(result *scan.ScanResult, _ int) {
+		results = append(results, result)
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("scanTable returned %v, want %v", err, failure)
+	}
+
+	merged := scan.NewScanner().Merge(results...)
+	if merged.Summary["email"] != 1 || len(merged.Columns) != 2 {
+		t.Errorf("rows read before the error not reported: %v, %d columns", merged.Summary, len(merged.Columns))
+	}
+}
+
+func TestScanTableMalformedRow(t *testing.T) {
+	input := "id,notes\n1,\"" + strings.Repeat("x", 40) + "\n2,bo@example.com\n"
+
+	var reported []string
+	e := New(Options{ChunkSize: 16, OnError: func(path string, err error) { reported = append(reported, err.Error()) }})
+	var results []*scan.ScanResult
+	err := e.scanTable(context.Background(), strings.NewReader(input), "notes.csv", ',', func(result *scan.ScanResult, _ int) {
+		results = append(results, result)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reported) != 1 || !strings.HasPrefix(reported[0], "notes.csv: line 2:") {
+		t.Errorf("reported %q, want the malformed row on line 2", reported)
+	}
+	if merged := scan.NewScanner().Merge(results...); merged.Summary["email"] != 1 {
+		t.Errorf("rows after the malformed one not scanned: %v", merged.Summary)
+	}
+}
+
+func TestRunDocuments(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"fixture.json": `{"users": [{"name": "x"}, {"contact": {"email": "ana@example.com"}}]}`,
+		"events.jsonl": "{\"id\": 1}\n{\"email\": \"bo@example.org\"}\n",
+		"config.yml":   "owner:\n  email: carl@example.com\n",
+		"broken.json":  `{"email": "dee@example.com"`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := New(Options{}).Run(context.Background(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]string)
+	for _, record := range result.PIIRecords {
+		got[filepath.Base(record.Location)] = fmt.Sprintf("%s %d %s %d:%d", record.Value, record.Row, record.Field, record.Line, record.Column)
+	}
+	want := map[string]string{
+		"fixture.json": "ana@example.com 0 $.users[1].contact.email 1:50",
+		"events.jsonl": "bo@example.org 2 $.email 2:12",
+		"config.yml":   "carl@example.com 0 $.owner.email 2:10",
+		"broken.json":  "dee@example.com 0  1:12",
+	}
+	for name, w := range want {
+		if got[name] != w {
+			t.Errorf("%s: found %q, want %q", name, got[name], w)
+		}
+	}
+
+	// Documents over the size limit are skipped, including those whose size
+	// is only known once decompressed
+	f, err := os.Create(filepath.Join(root, "export.json.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := gzip.NewWriter(f)
+	gw.Write([]byte(files["fixture.json"]))
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	result, err = New(Options{MaxDocumentSize: 40}).Run(context.Background(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Summary["email"] != 3 {
+		t.Errorf("found %d emails, want 3", result.Summary["email"])
+	}
+	skipped := make([]string, 0)
+	for _, file := range result.Files {
+		if file.Skipped && file.SkipReason == scan.SkipTooLarge {
+			skipped = append(skipped, strings.TrimPrefix(filepath.ToSlash(file.Location), filepath.ToSlash(root)+"/"))
+		}
+	}
+	sort.Strings(skipped)
+	if want := []string{"export.json.gz!/export.json", "fixture.json"}; strings.Join(skipped, "|") != strings.Join(want, "|") {
+		t.Errorf("skipped %q, want %q", skipped, want)
+	}
+}
+
+func TestRunOffice(t *testing.T) {
+	root := t.TempDir()
+	f, err := os.Create(filepath.Join(root, "letter.docx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	part, err := w.Create("word/document.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(part, `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>`+
+		`<w:p><w:r><w:t>Dear customer,</w:t></w:r></w:p>`+
+		`<w:p><w:r><w:t>write to ana@example.com</w:t></w:r></w:p>`+
+		`</w:body></w:document>`)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	// Office documents are read even though other binary files are skipped
+	result, err := New(Options{}).Run(context.Background(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.PIIRecords) != 1 {
+		t.Fatalf("found %d records, want 1", len(result.PIIRecords))
+	}
+	record := result.PIIRecords[0]
+	if record.Value != "ana@example.com" || record.Field != "paragraph 2" || record.Line != 0 || record.Start != 9 {
+		t.Errorf("found %q in %q at %d, line %d, want ana@example.com in paragraph 2 at 9", record.Value, record.Field, record.Start, record.Line)
+	}
+	if info := result.Files[0]; info.Type != "docx" || info.Skipped {
+		t.Errorf("file info %+v, want an unskipped docx", info)
+	}
+}
+
+func TestRunPDF(t *testing.T) {
+	content := "BT /F1 12 Tf 72 700 Td (Dear customer,) Tj 0 -14 Td (write to ana@example.com) Tj ET"
+	pdf := "%PDF-1.4\n" +
+		"1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n" +
+		"2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj\n" +
+		"3 0 obj << /Type /Page /Parent 2 0 R /Contents 4 0 R >> endobj\n" +
+		fmt.Sprintf("4 0 obj << /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(content), content) +
+		"trailer << /Root 1 0 R >>\n%%EOF\n"
+
+	// PDF files are recognized by their content, whatever their name
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "scan0001"), []byte(pdf), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := New(Options{}).Run(context.Background(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.PIIRecords) != 1 {
+		t.Fatalf("found %d records, want 1", len(result.PIIRecords))
+	}
+	record := result.PIIRecords[0]
+	if record.Value != "ana@example.com" || record.Field != "page 1" || record.Start != 24 {
+		t.Errorf("found %q in %q at %d, want ana@example.com in page 1 at 24", record.Value, record.Field, record.Start)
+	}
+}
+
+func TestRunArchive(t *testing.T) {
+	root := t.TempDir()
+	f, err := os.Create(filepath.Join(root, "backup.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	for _, entry := range [][2]string{
+		{"db/users.csv", "id,email\n1,ana@example.com\n"},
+		{"logs/app.log", "started\nlogin from bo@example.org\n"},
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: entry[0], Mode: 0o644, Size: int64(len(entry[1]))}); err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(tw, entry[1])
+	}
+	tw.Close()
+	gw.Close()
+	f.Close()
+
+	result, err := New(Options{}).Run(context.Background(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make([]string, 0)
+	for _, record := range result.PIIRecords {
+		rel, _ := filepath.Rel(root, record.Location)
+		got = append(got, fmt.Sprintf("%s %s:%d:%d", record.Value, rel, record.Line, record.Column))
+	}
+	want := "ana@example.com backup.tar.gz!/db/users.csv:2:3|bo@example.org backup.tar.gz!/logs/app.log:2:12"
+	if strings.Join(got, "|") != want {
+		t.Errorf("found %q, want %q", got, want)
+	}
+
+	types := make([]string, 0)
+	for _, info := range result.Files {
+		types = append(types, info.Type)
+	}
+	if strings.Join(types, " ") != "gzip text text" {
+		t.Errorf("files of types %v, want the archive and its two files", types)
+	}
+}
@@ -0,0 +1,274 @@
+package extract
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hallucinaut/privacyguard/pkg/scan"
+)
+
+// DefaultMaxCellSize is the default length limit of a cell, in bytes.
+const DefaultMaxCellSize = 4 << 20
+
+// ErrMalformedRow is returned by Read for a row with a cell longer than the
+// reader's MaxCellSize, as left by a quote that is never closed. The rest of
+// its line is skipped and reading may continue with the next row.
+var ErrMalformedRow = errors.New("malformed row: cell too long")
+
+// CSVReader reads the cells of delimited text such as CSV or TSV, keeping
+// the position of each in the input. Quoted cells follow RFC 4180 and may
+// span lines; a cell's Text is what lies between its quotes, with doubled
+// quotes left as written so that positions within it stay exact.
+type CSVReader struct {
+	// MaxCellSize bounds the length of a cell in bytes, so that an unclosed
+	// quote cannot read the rest of the input into one cell. Defaults to
+	// DefaultMaxCellSize.
+	MaxCellSize int
+
+	r       *bufio.Reader
+	comma   byte
+	header  []string
+	started bool
+	row     int
+	eof     bool
+
+	// Position of the next byte
+	offset       int64
+	line, column int
+}
+
+// NewCSVReader creates a reader of the cells of r, separated by comma.
+func NewCSVReader(r io.Reader, comma byte) *CSVReader {
+	return &CSVReader{r: bufio.NewReader(r), comma: comma, line: 1, column: 1}
+}
+
+// Header returns the column names, or nil if the first row holds data. It is
+// known after the first call to Read.
+func (c *CSVReader) Header() []string {
+	return c.header
+}
+
+// Offset returns the number of bytes read so far.
+func (c *CSVReader) Offset() int64 {
+	return c.offset
+}
+
+// Read returns the cells of the next data row, named after their columns.
+// It returns io.EOF after the last row, and an error wrapping
+// ErrMalformedRow for a row that is skipped.
+func (c *CSVReader) Read() ([]scan.Value, error) {
+	if !c.started {
+		c.started = true
+		first, err := c.readRow()
+		if err != nil {
+			return nil, err
+		}
+		if !isHeader(first) {
+			return c.label(first), nil
+		}
+		c.header = make([]string, len(first))
+		for i, cell := range first {
+			c.header[i] = strings.TrimSpace(strings.ReplaceAll(cell.Text, `""`, `"`))
+		}
+	}
+
+	row, err := c.readRow()
+	if errors.Is(err, ErrMalformedRow) {
+		// Later rows keep their numbers
+		c.row++
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.label(row), nil
+}
+
+// label numbers a data row and names its cells after their columns.
+func (c *CSVReader) label(row []scan.Value) []scan.Value {
+	c.row++
+	for i := range row {
+		row[i].Field = scan.ColumnName(c.header, i)
+		row[i].Row = c.row
+		if i < len(c.header) {
+			row[i].Hint = c.header[i]
+		}
+	}
+	return row
+}
+
+// isHeader reports whether the first row of a table looks like column
+// names rather than data: every cell has a letter and none holds an email
+// address or a long run of digits.
+func isHeader(row []scan.Value) bool {
+	for _, cell := range row {
+		if !strings.ContainsFunc(cell.Text, unicode.IsLetter) || strings.Contains(cell.Text, "@") {
+			return false
+		}
+		digits := 0
+		for _, r := range cell.Text {
+			if r < '0' || r > '9' {
+				digits = 0
+			} else if digits++; digits > 3 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// readRow reads the cells of the next non-empty line.
+func (c *CSVReader) readRow() ([]scan.Value, error) {
+	for {
+		row := make([]scan.Value, 0, len(c.header))
+		for {
+			cell, last, err := c.readCell()
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, cell)
+			if last {
+				break
+			}
+		}
+
+		if len(row) > 1 || row[0].Text != "" {
+			return row, nil
+		}
+	}
+}
+
+// readCell reads a cell and the delimiter or line break after it; last is
+// set at the end of a row. It returns io.EOF once the input has ended.
+func (c *CSVReader) readCell() (cell scan.Value, last bool, err error) {
+	if c.eof {
+		return cell, true, io.EOF
+	}
+
+	first, err := c.r.Peek(1)
+	if err != nil && err != io.EOF {
+		return cell, true, err
+	}
+
+	quoted := len(first) == 1 && first[0] == '"'
+	if quoted {
+		c.readByte()
+	}
+	cell = scan.Value{Offset: c.offset, Line: c.line, Column: c.column}
+
+	maxCell := c.MaxCellSize
+	if maxCell <= 0 {
+		maxCell = DefaultMaxCellSize
+	}
+	var text strings.Builder
+
+	for {
+		if text.Len() > maxCell {
+			if err := c.skipLine(); err != nil {
+				return cell, true, err
+			}
+			return cell, true, fmt.Errorf("line %d: %w", cell.Line, ErrMalformedRow)
+		}
+
+		b, err := c.readByte()
+		if err == io.EOF {
+			last = true
+			break
+		} else if err != nil {
+			return cell, true, err
+		}
+
+		if quoted {
+			if b != '"' {
+				text.WriteByte(b)
+				continue
+			}
+			if next, _ := c.r.Peek(1); len(next) == 1 && next[0] == '"' {
+				c.readByte()
+				text.WriteString(`""`)
+				continue
+			}
+			// Anything between the closing quote and the delimiter is
+			// dropped
+			if err := c.skipToDelimiter(&last); err != nil {
+				return cell, true, err
+			}
+			break
+		}
+
+		if b == c.comma {
+			break
+		}
+		if b == '\n' {
+			last = true
+			break
+		}
+		text.WriteByte(b)
+	}
+
+	cell.Text = text.String()
+	if !quoted {
+		cell.Text = strings.TrimSuffix(cell.Text, "\r")
+	}
+	return cell, last, nil
+}
+
+// skipToDelimiter consumes input up to and including the next delimiter or
+// line break, setting last at a line break or the end of the input.
+func (c *CSVReader) skipToDelimiter(last *bool) error {
+	for {
+		b, err := c.readByte()
+		if err == io.EOF {
+			*last = true
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if b == c.comma {
+			return nil
+		}
+		if b == '\n' {
+			*last = true
+			return nil
+		}
+	}
+}
+
+// skipLine consumes input up to and including the next line break.
+func (c *CSVReader) skipLine() error {
+	for {
+		b, err := c.readByte()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if b == '\n' {
+			return nil
+		}
+	}
+}
+
+// readByte reads a byte, advancing the position.
+func (c *CSVReader) readByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == io.EOF {
+		c.eof = true
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	c.offset++
+	if b == '\n' {
+		c.line++
+		c.column = 1
+	} else if utf8.RuneStart(b) {
+		c.column++
+	}
+	return b, nil
+}
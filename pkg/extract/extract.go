@@ -0,0 +1,110 @@
+// Package extract reads the values held in structured files, so that each
+// can be scanned on its own with the name of its field as context.
+package extract
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hallucinaut/privacyguard/pkg/scan"
+)
+
+// Structured formats recognized by Structure.
+const (
+	FormatCSV    = "csv"
+	FormatTSV    = "tsv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatYAML   = "yaml"
+	FormatOffice = "office"
+	FormatPDF    = "pdf"
+)
+
+// Structure returns the structured format of a file named path, judging by
+// its extension, or "" for files read as plain text.
+func Structure(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV
+	case ".tsv", ".tab":
+		return FormatTSV
+	case ".json":
+		return FormatJSON
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".docx", ".docm", ".xlsx", ".xlsm", ".pptx", ".pptm", ".odt", ".ods", ".odp":
+		return FormatOffice
+	case ".pdf":
+		return FormatPDF
+	}
+	return ""
+}
+
+// lineIndex maps between byte offsets and line and column positions in a
+// document held in memory.
+type lineIndex struct {
+	content string
+	starts  []int // Offset of the start of each line
+}
+
+func newLineIndex(content string) *lineIndex {
+	starts := []int{0}
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return &lineIndex{content: content, starts: starts}
+}
+
+// value returns a value of the document at [start, end), positioned.
+func (l *lineIndex) value(start, end int, field string) scan.Value {
+	line := sort.SearchInts(l.starts, start+1)
+	return scan.Value{
+		Text:   l.content[start:end],
+		Field:  field,
+		Offset: int64(start),
+		Line:   line,
+		Column: scan.CountChars(l.content[l.starts[line-1]:start]) + 1,
+	}
+}
+
+// offset returns the byte offset of a line and column, both counting from 1
+// and columns counting characters.
+func (l *lineIndex) offset(line, column int) int {
+	if line < 1 || line > len(l.starts) {
+		return len(l.content)
+	}
+	offset := l.starts[line-1]
+	for ; column > 1 && offset < len(l.content); column-- {
+		_, size := utf8.DecodeRuneInString(l.content[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// childPath extends a JSON path such as "$.users" by an object key, using
+// dot notation for identifiers and bracket notation for other keys.
+func childPath(path, key string) string {
+	identifier := key != ""
+	for i, r := range key {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+			identifier = false
+			break
+		}
+	}
+	if identifier {
+		return path + "." + key
+	}
+	return path + "['" + strings.ReplaceAll(key, "'", `\'`) + "']"
+}
+
+// indexPath extends a JSON path by an array index.
+func indexPath(path string, index int) string {
+	return path + "[" + strconv.Itoa(index) + "]"
+}
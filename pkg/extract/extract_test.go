@@ -0,0 +1,476 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/hallucinaut/privacyguard/pkg/scan"
+)
+
+func TestCSVReader(t *testing.T) {
+	input := "name,email,notes\r\n" +
+		"Zoë Adams,zoe@example.com,\"said \"\"hi\"\"\r\nthen left\"\r\n" +
+		"\r\n" +
+		"Li Wei,li@example.com,,extra"
+
+	reader := NewCSVReader(strings.NewReader(input), ',')
+	rows := make([][]scan.Value, 0)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+
+	if got := strings.Join(reader.Header(), "|"); got != "name|email|notes" {
+		t.Errorf("Header = %q, want name|email|notes", got)
+	}
+	if len(rows) != 2 || len(rows[0]) != 3 || len(rows[1]) != 4 {
+		t.Fatalf("read rows %v", rows)
+	}
+
+	want := []scan.Value{
+		{Text: "Zoë Adams", Field: "name", Hint: "name", Row: 1, Offset: 18, Line: 2, Column: 1},
+		{Text: "zoe@example.com", Field: "email", Hint: "email", Row: 1, Offset: 29, Line: 2, Column: 11},
+		{Text: "said \"\"hi\"\"\r\nthen left", Field: "notes", Hint: "notes", Row: 1, Offset: 46, Line: 2, Column: 28},
+		{Text: "extra", Field: "column 4", Row: 2, Offset: 96, Line: 5, Column: 24},
+	}
+	got := []scan.Value{rows[0][0], rows[0][1], rows[0][2], rows[1][3]}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("cell %d = %+v, want %+v", i, got[i], want[i])
+		}
+		if text := input[got[i].Offset:]; !strings.HasPrefix(text, got[i].Text) {
+			t.Errorf("cell %d: input at offset %d is %q, want %q", i, got[i].Offset, text, got[i].Text)
+		}
+	}
+}
+
+func TestCSVReaderWithoutHeader(t *testing.T) {
+	reader := NewCSVReader(strings.NewReader("1\tjo@example.com\n2\tal@example.com\n"), '\t')
+	row, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reader.Header() != nil {
+		t.Errorf("Header = %q, want none", reader.Header())
+	}
+	if row[1].Text != "jo@example.com" || row[1].Field != "column 2" || row[1].Row != 1 {
+		t.Errorf("first row = %+v", row)
+	}
+}
+
+func TestCSVReaderUnclosedQuote(t *testing.T) {
+	input := "id,notes\n1,\"" + strings.Repeat("x", 40) + "\n2,bo@example.com\n"
+	reader := NewCSVReader(strings.NewReader(input), ',')
+	reader.MaxCellSize = 16
+
+	if _, err := reader.Read(); !errors.Is(err, ErrMalformedRow) {
+		t.Fatalf("Read of an unclosed quote returned %v, want %v", err, ErrMalformedRow)
+	}
+	row, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row[1].Text != "bo@example.com" || row[1].Row != 2 || row[1].Line != 3 {
+		t.Errorf("row after the malformed one = %+v", row)
+	}
+	if _, err := reader.Read(); err != io.EOF {
+		t.Errorf("Read at the end returned %v, want io.EOF", err)
+	}
+}
+
+// checkPositions checks that each value lies at its offset, line and
+// column in content, unless it was decoded from escapes.
+func checkPositions(t *testing.T, content string, values []scan.Value) {
+	t.Helper()
+	for _, value := range values {
+		before := content[:value.Offset]
+		line := strings.Count(before, "\n") + 1
+		column := utf8.RuneCountInString(before[strings.LastIndexByte(before, '\n')+1:]) + 1
+		if value.Line != line || value.Column != column {
+			t.Errorf("%s %q at %d:%d, want %d:%d", value.Field, value.Text, value.Line, value.Column, line, column)
+		}
+		if !strings.HasPrefix(content[value.Offset:], value.Text) && !strings.Contains(value.Text, "é") {
+			t.Errorf("%s %q at offset %d, where the content is %.20q", value.Field, value.Text, value.Offset, content[value.Offset:])
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	content := `{"users": [{"id": 7, "contact": {"e-mail": "ana@example.com"}}],
+ "note": "café \"x\"", "ok": true, "n": null}
+{"row": 2}`
+
+	values, err := JSON(content, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPositions(t, content, values)
+
+	want := []string{
+		"1 $  users", "1 $.users[0] users id", "1 $.users[0].id id 7", "1 $.users[0] users contact",
+		"1 $.users[0].contact contact e-mail", "1 $.users[0].contact['e-mail'] e-mail ana@example.com",
+		"1 $  note", `1 $.note note café \"x\"`, "1 $  ok", "1 $  n", "2 $  row", "2 $.row row 2",
+	}
+	got := make([]string, 0, len(values))
+	for _, value := range values {
+		got = append(got, fmt.Sprintf("%d %s %s %s", value.Row, value.Field, value.Hint, value.Text))
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("values:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	for _, bad := range []string{`{"a": `, `{"a": 1}}`, `plain text`} {
+		if _, err := JSON(bad, false); err == nil {
+			t.Errorf("JSON(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestYAML(t *testing.T) {
+	content := "admin:\n" +
+		"  email: 'ana@example.com'\n" +
+		"  notes: |\n" +
+		"    call 415-872-3391\n" +
+		"\n" +
+		"    or write\n" +
+		"  active: true\n" +
+		"hosts:\n" +
+		"  - name: \"caf\\u00e9\"\n" +
+		"    tags: [a, b]\n" +
+		"---\n" +
+		"owner: bo@example.org\n"
+
+	values, err := YAML(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPositions(t, content, values)
+
+	want := []string{
+		"$  admin", "$.admin admin email", "$.admin.email email ana@example.com",
+		"$.admin admin notes", "$.admin.notes notes     call 415-872-3391\n\n    or write", "$.admin admin active",
+		"$  hosts", "$.hosts[0] hosts name", "$.hosts[0].name name café", "$.hosts[0] hosts tags",
+		"$.hosts[0].tags[0] tags a", "$.hosts[0].tags[1] tags b", "$  owner", "$.owner owner bo@example.org",
+	}
+	got := make([]string, 0, len(values))
+	for _, value := range values {
+		got = append(got, value.Field+" "+value.Hint+" "+value.Text)
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("values:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if _, err := YAML("a: [1, 2\n"); err == nil {
+		t.Errorf("YAML of malformed content succeeded, want an error")
+	}
+}
+
+// zipped returns a zip archive of the given files, in name order.
+func zipped(t *testing.T, files map[string]string) *bytes.Reader {
+	t.Helper()
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range names {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestOffice(t *testing.T) {
+	const (
+		w   = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+		cp  = `xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/"`
+		ss  = `xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"`
+		a   = `xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main"`
+		pr  = `xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main"`
+		od  = `xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:meta="urn:oasis:names:tc:opendocument:xmlns:meta:1.0"`
+		tb  = `xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"`
+		rel = `xmlns="http://schemas.openxmlformats.org/package/2006/relationships"`
+	)
+	core := `<cp:coreProperties ` + cp + `><dc:creator>Ana Lima</dc:creator><cp:lastModifiedBy>bo@example.org</cp:lastModifiedBy></cp:coreProperties>`
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		kind  string
+		want  []string
+	}{
+		{
+			name: "docx",
+			files: map[string]string{
+				"word/document.xml": `<w:document ` + w + `><w:body>` +
+					`<w:p><w:r><w:t>Contact: </w:t></w:r><w:r><w:t>ana@example.com</w:t></w:r></w:p>` +
+					`<w:p/>` +
+					`<w:p><w:r><w:t>Call</w:t><w:tab/><w:t>415-872-3391</w:t></w:r></w:p>` +
+					`</w:body></w:document>`,
+				"word/header1.xml":  `<w:hdr ` + w + `><w:p><w:r><w:t>Confidential</w:t></w:r></w:p></w:hdr>`,
+				"word/comments.xml": `<w:comments ` + w + `><w:comment w:id="0" w:author="Carl Diaz"><w:p><w:r><w:t>check this</w:t></w:r></w:p></w:comment></w:comments>`,
+				"docProps/core.xml": core,
+			},
+			kind: KindDOCX,
+			want: []string{
+				"paragraph 1 [] Contact: ana@example.com",
+				"paragraph 3 [] Call\t415-872-3391",
+				"header 1, paragraph 1 [] Confidential",
+				"comment 0 [] check this",
+				"comment 0 author [author] Carl Diaz",
+				"metadata: author [author] Ana Lima",
+				"metadata: last modified by [last modified by] bo@example.org",
+			},
+		},
+		{
+			name: "xlsx",
+			files: map[string]string{
+				"xl/workbook.xml":            `<workbook ` + ss + `><sheets><sheet name="Staff" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+				"xl/_rels/workbook.xml.rels": `<Relationships ` + rel + `><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`,
+				"xl/sharedStrings.xml":       `<sst ` + ss + `><si><t>Email</t></si><si><r><t>dee@</t></r><r><t>example.com</t></r></si></sst>`,
+				"xl/worksheets/sheet1.xml": `<worksheet ` + ss + `><sheetData>` +
+					`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="inlineStr"><is><t>Phone</t></is></c></row>` +
+					`<row r="2"><c r="A2" t="s"><v>1</v></c><c r="B2"><f>1+1</f><v>4158723391</v></c></row>` +
+					`</sheetData></worksheet>`,
+				"xl/worksheets/_rels/sheet1.xml.rels": `<Relationships ` + rel + `><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/comments" Target="../comments1.xml"/></Relationships>`,
+				"xl/comments1.xml":                    `<comments ` + ss + `><authors><author>Eve Park</author></authors><commentList><comment ref="B2" authorId="0"><text><r><t>mobile</t></r></text></comment></commentList></comments>`,
+			},
+			kind: KindXLSX,
+			want: []string{
+				"Staff!A1 [Email] Email",
+				"Staff!B1 [Phone] Phone",
+				"Staff!A2 [Email] dee@example.com",
+				"Staff!B2 [Phone] 4158723391",
+				"Staff!B2 comment [] mobile",
+				"Staff!B2 comment author [author] Eve Park",
+			},
+		},
+		{
+			name: "pptx",
+			files: map[string]string{
+				"ppt/presentation.xml":            `<p:presentation ` + pr + `/>`,
+				"ppt/slides/slide10.xml":          `<p:sld ` + a + `><a:p><a:r><a:t>Later</a:t></a:r></a:p></p:sld>`,
+				"ppt/slides/slide2.xml":           `<p:sld ` + a + `><a:p><a:r><a:t>Owner:</a:t></a:r><a:br/><a:r><a:t>fay@example.com</a:t></a:r></a:p></p:sld>`,
+				"ppt/notesSlides/notesSlide2.xml": `<p:notes ` + a + `><a:p><a:r><a:t>speaker notes</a:t></a:r></a:p></p:notes>`,
+				"ppt/commentAuthors.xml":          `<p:cmAuthorLst ` + pr + `><p:cmAuthor id="0" name="Gus Ito"/></p:cmAuthorLst>`,
+				"ppt/comments/comment2.xml":       `<p:cmLst ` + pr + `><p:cm authorId="0"><p:text>fix typo</p:text></p:cm></p:cmLst>`,
+			},
+			kind: KindPPTX,
+			want: []string{
+				"slide 2, paragraph 1 [] Owner:\nfay@example.com",
+				"slide 10, paragraph 1 [] Later",
+				"notes 2, paragraph 1 [] speaker notes",
+				"comment author [author] Gus Ito",
+				"comments 2, comment 1 [] fix typo",
+			},
+		},
+		{
+			name: "odt",
+			files: map[string]string{
+				"mimetype": "application/vnd.oasis.opendocument.text",
+				"content.xml": `<office:document-content ` + od + `><office:body><office:text>` +
+					`<text:h>Notes</text:h>` +
+					`<text:p>Mail<text:s/>hal@example.com<office:annotation><dc:creator>Ida Kim</dc:creator><text:p>old</text:p></office:annotation></text:p>` +
+					`</office:text></office:body></office:document-content>`,
+				"meta.xml": `<office:document-meta ` + od + `><office:meta><meta:initial-creator>Jo Lund</meta:initial-creator></office:meta></office:document-meta>`,
+			},
+			kind: KindODT,
+			want: []string{
+				"paragraph 1 [] Notes",
+				"comment author [author] Ida Kim",
+				"paragraph 2 [] old",
+				"paragraph 3 [] Mail hal@example.com",
+				"metadata: author [author] Jo Lund",
+			},
+		},
+		{
+			name: "ods",
+			files: map[string]string{
+				"mimetype": "application/vnd.oasis.opendocument.spreadsheet",
+				"content.xml": `<office:document-content ` + od + ` ` + tb + `><office:body><office:spreadsheet><table:table table:name="Staff">` +
+					`<table:table-row><table:table-cell><text:p>Email</text:p></table:table-cell>` +
+					`<table:table-cell table:number-columns-repeated="2"/><table:table-cell><text:p>Phone</text:p></table:table-cell></table:table-row>` +
+					`<table:table-row table:number-rows-repeated="3"><table:table-cell/></table:table-row>` +
+					`<table:table-row><table:table-cell><text:p>dee@example.com</text:p>` +
+					`<office:annotation><dc:creator>Eve Park</dc:creator><dc:date>2024-01-02</dc:date><text:p>mobile</text:p></office:annotation></table:table-cell>` +
+					`<table:covered-table-cell table:number-columns-repeated="2"/><table:table-cell><text:p>4158723391</text:p></table:table-cell></table:table-row>` +
+					`</table:table></office:spreadsheet></office:body></office:document-content>`,
+			},
+			kind: KindODS,
+			want: []string{
+				"Staff!A1 [Email] Email",
+				"Staff!D1 [Phone] Phone",
+				"Staff!A5 [Email] dee@example.com",
+				"Staff!A5 comment [] mobile",
+				"Staff!A5 comment author [author] Eve Park",
+				"Staff!D5 [Phone] 4158723391",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := zipped(t, tt.files)
+			kind, values, err := Office(r, r.Size())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kind != tt.kind {
+				t.Errorf("kind = %q, want %q", kind, tt.kind)
+			}
+
+			got := make([]string, 0, len(values))
+			for _, value := range values {
+				got = append(got, value.Field+" ["+value.Hint+"] "+value.Text)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("values:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+
+	r := zipped(t, map[string]string{"readme.txt": "not a document"})
+	if _, _, err := Office(r, r.Size()); err == nil {
+		t.Errorf("Office of a plain zip succeeded, want an error")
+	}
+
+	// Parts each under maxPartSize still share the document's budget
+	defer func(size int64) { maxDocumentSize = size }(maxDocumentSize)
+	maxDocumentSize = 4 << 10
+	files := map[string]string{"word/document.xml": `<w:document ` + w + `/>`}
+	header := `<w:hdr ` + w + `><w:p><w:r><w:t>` + strings.Repeat("x", 1<<10) + `</w:t></w:r></w:p></w:hdr>`
+	for i := 1; i <= 8; i++ {
+		files["word/header"+strconv.Itoa(i)+".xml"] = header
+	}
+	r = zipped(t, files)
+	if _, _, err := Office(r, r.Size()); !errors.Is(err, errDocumentTooLarge) {
+		t.Errorf("Office of a document over its budget returned %v, want %v", err, errDocumentTooLarge)
+	}
+}
+
+// buildPDF returns a PDF file of the given objects, numbered from 1, and
+// trailer dictionary, leaving out empty objects. Readers find objects without
+// a cross reference table.
+func buildPDF(objects []string, trailer string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.5\n%\xe2\xe3\xcf\xd3\n")
+	for i, obj := range objects {
+		if obj == "" {
+			continue
+		}
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	buf.WriteString("trailer\n" + trailer + "\n%%EOF\n")
+	return buf.Bytes()
+}
+
+// streamObject returns a stream object, Flate compressed if filter is set.
+func streamObject(dict, data string, filter bool) string {
+	if filter {
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		w.Write([]byte(data))
+		w.Close()
+		data = buf.String()
+		dict += " /Filter /FlateDecode"
+	}
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+func TestPDF(t *testing.T) {
+	cmap := "/CIDInit /ProcSet findresource begin 12 dict begin begincmap\n" +
+		"1 begincodespacerange <0000> <FFFF> endcodespacerange\n" +
+		"3 beginbfchar <0001> <005A> <0002> <006F> <0003> <00EB> endbfchar\n" +
+		"1 beginbfrange <0010> <0012> <0078> endbfrange\n" +
+		"endcmap CMapName currentdict /CMap defineresource pop end end"
+	font := "<< /Type /Font /Subtype /Type0 /BaseFont /Demo /ToUnicode 9 0 R >>"
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [4 0 R 3 0 R] /Count 2 /Resources << /Font << /F1 5 0 R /F2 6 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents [8 0 R] >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 7 0 R >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		"", // In the object stream
+		streamObject("", "BT /F1 12 Tf 72 700 Td (Contact: \\(ana\\)) Tj 0 -14 Td [(bo@exa) -20 (mple.org)] TJ\n"+
+			"T* [(Call) -300 (415-872-3391)] TJ ET\n"+
+			"BI /W 2 /H 1 /BPC 8 /CS /G ID \x00\xff EI", false),
+		streamObject("", "BT /F2 10 Tf 1 0 0 1 72 700 Tm <000100020003> Tj 1 0 0 1 120 700 Tm <001000110012> Tj ET", true),
+		streamObject("", cmap, true),
+		"<< /Author (Ana Lima) /Title <FEFF004800E9> /Producer (Demo) >>",
+		streamObject("/Type /ObjStm /N 1 /First 4", "6 0 "+font, true),
+	}
+	data := buildPDF(objects, "<< /Root 1 0 R /Info 10 0 R /Size 12 >>")
+
+	values, err := PDF(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"page 1 [] Contact: (ana)\nbo@example.org\nCall 415-872-3391",
+		"page 2 [] Zoë xyz",
+		"metadata: author [author] Ana Lima",
+		"metadata: title [title] Hé",
+	}
+	got := make([]string, 0, len(values))
+	for _, value := range values {
+		got = append(got, value.Field+" ["+value.Hint+"] "+value.Text)
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("values:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	encrypted := buildPDF(objects[:1], "<< /Root 1 0 R /Encrypt << /Filter /Standard >> >>")
+	for name, bad := range map[string][]byte{"encrypted": encrypted, "not a PDF": []byte("plain text")} {
+		if _, err := PDF(bad); err == nil {
+			t.Errorf("PDF of %s file succeeded, want an error", name)
+		}
+	}
+
+	// Predictor parameters wider than the data are rejected, not allocated
+	for _, params := range []string{
+		"/Predictor 12 /Columns 4000000000000000000",
+		"/Predictor 12 /Colors 4000000000 /Columns 2",
+		"/Predictor 12 /BitsPerComponent 3",
+	} {
+		hostile := append([]string(nil), objects...)
+		hostile[7] = streamObject("/DecodeParms << "+params+" >>", "BT /F2 10 Tf <0001> Tj ET", true)
+		values, err := PDF(buildPDF(hostile, "<< /Root 1 0 R /Size 12 >>"))
+		if err != nil || len(values) != 1 || values[0].Field != "page 1" {
+			t.Errorf("%s: values %v, error %v; want page 1 alone", params, values, err)
+		}
+	}
+
+	// The content streams of all pages share the document's budget
+	defer func(size int64) { maxDocumentSize = size }(maxDocumentSize)
+	maxDocumentSize = 150
+	if _, err := PDF(data); !errors.Is(err, errDocumentTooLarge) {
+		t.Errorf("PDF over its budget returned %v, want %v", err, errDocumentTooLarge)
+	}
+}
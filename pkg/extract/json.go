@@ -0,0 +1,121 @@
+package extract
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hallucinaut/privacyguard/pkg/scan"
+)
+
+// JSON returns the strings, numbers and object keys in JSON content, each
+// named by its JSON path, such as "$.users[3].contact.email", and hinted by
+// the key it is stored under. Keys are named by the path of their object.
+// Strings are returned as written between their quotes, escapes included, so
+// that positions within them stay exact.
+//
+// Content may hold several top-level values, as NDJSON does; with lines set,
+// the values of each are numbered as rows.
+func JSON(content string, lines bool) ([]scan.Value, error) {
+	d := &jsonDocument{
+		decoder: json.NewDecoder(strings.NewReader(content)),
+		index:   newLineIndex(content),
+		values:  make([]scan.Value, 0),
+	}
+	d.decoder.UseNumber()
+
+	for row := 1; d.decoder.More(); row++ {
+		if lines {
+			d.row = row
+		}
+		if err := d.walk("$", ""); err != nil {
+			return nil, err
+		}
+	}
+
+	// Anything left, such as a stray closing bracket, is an error
+	if _, err := d.decoder.Token(); err != io.EOF {
+		if err == nil {
+			err = fmt.Errorf("unexpected token at offset %d", d.decoder.InputOffset())
+		}
+		return nil, err
+	}
+	return d.values, nil
+}
+
+// jsonDocument walks the tokens of a JSON document.
+type jsonDocument struct {
+	decoder *json.Decoder
+	index   *lineIndex
+	values  []scan.Value
+	row     int
+	end     int // Offset just past the last token read
+}
+
+// next reads a token and returns its offsets in the content.
+func (d *jsonDocument) next() (token json.Token, start, end int, err error) {
+	token, err = d.decoder.Token()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	start = d.end
+	end = int(d.decoder.InputOffset())
+	d.end = end
+
+	// Skip the whitespace, colon or comma before the token
+	for start < end && strings.IndexByte(" \t\r\n:,", d.index.content[start]) >= 0 {
+		start++
+	}
+	return token, start, end, nil
+}
+
+// walk reads a value and everything in it; hint is the key the value is
+// stored under, directly or in an array.
+func (d *jsonDocument) walk(path, hint string) error {
+	token, start, end, err := d.next()
+	if err != nil {
+		return err
+	}
+
+	switch token := token.(type) {
+	case json.Delim:
+		if token == '{' {
+			for d.decoder.More() {
+				key, start, end, err := d.next()
+				if err != nil {
+					return err
+				}
+				d.add(start+1, end-1, path, hint)
+				if err := d.walk(childPath(path, key.(string)), key.(string)); err != nil {
+					return err
+				}
+			}
+		} else {
+			for i := 0; d.decoder.More(); i++ {
+				if err := d.walk(indexPath(path, i), hint); err != nil {
+					return err
+				}
+			}
+		}
+		_, _, _, err := d.next()
+		return err
+	case string:
+		d.add(start+1, end-1, path, hint)
+	case json.Number:
+		d.add(start, end, path, hint)
+	}
+	return nil
+}
+
+// add records the value at [start, end) unless it is empty.
+func (d *jsonDocument) add(start, end int, path, hint string) {
+	if start >= end {
+		return
+	}
+	value := d.index.value(start, end, path)
+	value.Hint = hint
+	value.Row = d.row
+	d.values = append(d.values, value)
+}
@@ -0,0 +1,737 @@
+package extract
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hallucinaut/privacyguard/pkg/scan"
+)
+
+// Office document kinds returned by Office.
+const (
+	KindDOCX = "docx"
+	KindXLSX = "xlsx"
+	KindPPTX = "pptx"
+	KindODT  = "odt"
+	KindODS  = "ods"
+	KindODP  = "odp"
+)
+
+// maxPartSize bounds the uncompressed size of a part of an Office document,
+// so that a small, highly compressed file cannot exhaust memory.
+const maxPartSize = 256 << 20
+
+// maxDocumentSize bounds the uncompressed bytes read from all the parts of an
+// Office document, or the content streams of all the pages of a PDF file,
+// so that many parts just under maxPartSize cannot exhaust memory either.
+var maxDocumentSize int64 = 512 << 20
+
+var (
+	errPartTooLarge     = errors.New("document part too large")
+	errDocumentTooLarge = errors.New("document too large")
+)
+
+// documentBudget holds what is left of maxDocumentSize for one document.
+type documentBudget struct {
+	size int64 // Uncompressed bytes that may still be read
+}
+
+// spend counts n bytes read against the budget, failing once it is used up.
+func (b *documentBudget) spend(n int) error {
+	if b.size -= int64(n); b.size < 0 {
+		return errDocumentTooLarge
+	}
+	return nil
+}
+
+// Office returns the text in an OOXML (DOCX, XLSX, PPTX) or OpenDocument
+// (ODT, ODS, ODP) file: paragraphs of the body, headers, footers, notes and
+// slides; spreadsheet cells; comments and their authors; and document
+// metadata such as the author. Values have no position in the file, which is
+// compressed; their Field locates them instead, as in "paragraph 12",
+// "Sheet1!B7" or "metadata: author". It also returns the kind of document.
+func Office(r io.ReaderAt, size int64) (kind string, values []scan.Value, err error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return "", nil, err
+	}
+	o := &officeDocument{
+		parts:  make(map[string]*zip.File),
+		values: make([]scan.Value, 0),
+		budget: &documentBudget{size: maxDocumentSize},
+	}
+	for _, f := range zr.File {
+		o.parts[f.Name] = f
+	}
+
+	switch {
+	case o.parts["word/document.xml"] != nil:
+		kind, err = KindDOCX, o.docx()
+	case o.parts["xl/workbook.xml"] != nil:
+		kind, err = KindXLSX, o.xlsx()
+	case o.parts["ppt/presentation.xml"] != nil:
+		kind, err = KindPPTX, o.pptx()
+	case o.parts["content.xml"] != nil:
+		if kind = o.odfKind(); kind == "" {
+			return "", nil, fmt.Errorf("not an Office document")
+		}
+		err = o.odf(kind)
+	default:
+		return "", nil, fmt.Errorf("not an Office document")
+	}
+	if err != nil {
+		return kind, nil, err
+	}
+	return kind, o.values, nil
+}
+
+// officeDocument collects the values of an Office document.
+type officeDocument struct {
+	parts  map[string]*zip.File
+	values []scan.Value
+	budget *documentBudget // Shared by all the parts read
+}
+
+// add records a value unless it is blank.
+func (o *officeDocument) add(text, field, hint string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	o.values = append(o.values, scan.Value{Text: text, Field: field, Hint: hint})
+}
+
+// open opens a part of the document; a missing part reads as empty.
+func (o *officeDocument) open(name string) (io.ReadCloser, error) {
+	f := o.parts[name]
+	if f == nil {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &limitedPart{ReadCloser: rc, remaining: maxPartSize, budget: o.budget}, nil
+}
+
+// limitedPart fails reads past maxPartSize bytes, or once the document's
+// budget is used up.
+type limitedPart struct {
+	io.ReadCloser
+	remaining int64
+	budget    *documentBudget
+}
+
+func (l *limitedPart) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		return 0, errPartTooLarge
+	}
+	if l.budget.size <= 0 {
+		return 0, errDocumentTooLarge
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	if int64(len(p)) > l.budget.size {
+		p = p[:l.budget.size]
+	}
+	n, err := l.ReadCloser.Read(p)
+	l.remaining -= int64(n)
+	l.budget.size -= int64(n)
+	return n, err
+}
+
+// partsMatching returns the names of parts named prefix, a number and
+// suffix, such as "ppt/slides/slide2.xml", in numeric order.
+func (o *officeDocument) partsMatching(prefix, suffix string) []string {
+	names := make([]string, 0)
+	for name := range o.parts {
+		if number := strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix); number != name && isDigits(number) {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) < len(names[j])
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// partNumber returns the number in a part name matched by partsMatching.
+func partNumber(name, prefix, suffix string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix)
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// textStyle describes how an XML vocabulary lays out text.
+type textStyle struct {
+	units    map[string]bool   // Elements whose text forms one value, such as paragraphs
+	text     map[string]bool   // Elements holding text; nil for all character data in a unit
+	breaks   map[string]string // Elements standing for text, such as tabs and line breaks
+	newlines map[string]bool   // Elements ending a line within a unit
+}
+
+// readText reads the units of text in an XML part, calling emit with the
+// start element and text of each. Units may nest, as notes do inside
+// OpenDocument paragraphs; each is emitted when it ends.
+func (o *officeDocument) readText(name string, style textStyle, emit func(unit xml.StartElement, text string)) error {
+	rc, err := o.open(name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	type open struct {
+		start xml.StartElement
+		text  strings.Builder
+	}
+	stack := make([]*open, 0)
+	inText := 0
+
+	decoder := xml.NewDecoder(rc)
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			local := token.Name.Local
+			if style.units[local] {
+				stack = append(stack, &open{start: token.Copy()})
+			}
+			if len(stack) == 0 {
+				continue
+			}
+			if style.text[local] {
+				inText++
+			}
+			if text, exists := style.breaks[local]; exists {
+				stack[len(stack)-1].text.WriteString(text)
+			}
+		case xml.EndElement:
+			local := token.Name.Local
+			if len(stack) == 0 {
+				continue
+			}
+			if style.text[local] {
+				inText--
+			}
+			if style.newlines[local] {
+				stack[len(stack)-1].text.WriteByte('\n')
+			}
+			if style.units[local] {
+				unit := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				emit(unit.start, strings.TrimRight(unit.text.String(), "\n"))
+			}
+		case xml.CharData:
+			if len(stack) > 0 && (style.text == nil || inText > 0) {
+				stack[len(stack)-1].text.Write(token)
+			}
+		}
+	}
+}
+
+// attr returns the value of an element's attribute with the given local
+// name.
+func attr(element xml.StartElement, local string) string {
+	for _, a := range element.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// paragraphs reads the paragraphs of a part, naming each with prefix and its
+// number.
+func (o *officeDocument) paragraphs(name string, style textStyle, prefix string) error {
+	n := 0
+	return o.readText(name, style, func(_ xml.StartElement, text string) {
+		n++
+		o.add(text, prefix+"paragraph "+strconv.Itoa(n), "")
+	})
+}
+
+// metadataFields names the metadata elements that may hold PII, by local
+// name, in OOXML core and app properties and OpenDocument meta.xml.
+var metadataFields = map[string]string{
+	"creator":         "author",
+	"initial-creator": "author",
+	"lastModifiedBy":  "last modified by",
+	"title":           "title",
+	"subject":         "subject",
+	"description":     "description",
+	"keywords":        "keywords",
+	"keyword":         "keywords",
+	"Company":         "company",
+	"Manager":         "manager",
+}
+
+// metadata reads document properties from the given parts.
+func (o *officeDocument) metadata(names ...string) error {
+	style := textStyle{units: make(map[string]bool)}
+	for local := range metadataFields {
+		style.units[local] = true
+	}
+	for _, name := range names {
+		err := o.readText(name, style, func(unit xml.StartElement, text string) {
+			label := metadataFields[unit.Name.Local]
+			o.add(text, "metadata: "+label, label)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// relationship is an entry of an OOXML relationships part.
+type relationship struct {
+	ID     string `xml:"Id,attr"`
+	Type   string `xml:"Type,attr"`
+	Target string `xml:"Target,attr"`
+}
+
+// relationships returns the relationships of an OOXML part, with targets
+// resolved to part names.
+func (o *officeDocument) relationships(name string) ([]relationship, error) {
+	relsName := path.Join(path.Dir(name), "_rels", path.Base(name)+".rels")
+	rc, err := o.open(relsName)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var rels struct {
+		Relationships []relationship `xml:"Relationship"`
+	}
+	if err := xml.NewDecoder(rc).Decode(&rels); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%s: %w", relsName, err)
+	}
+	for i := range rels.Relationships {
+		target := rels.Relationships[i].Target
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(target, "/")
+		} else {
+			target = path.Join(path.Dir(name), target)
+		}
+		rels.Relationships[i].Target = target
+	}
+	return rels.Relationships, nil
+}
+
+// wordText is the text layout of WordprocessingML.
+var wordText = textStyle{
+	units:  map[string]bool{"p": true},
+	text:   map[string]bool{"t": true},
+	breaks: map[string]string{"tab": "\t", "br": "\n", "cr": "\n"},
+}
+
+// docx reads a Word document.
+func (o *officeDocument) docx() error {
+	if err := o.paragraphs("word/document.xml", wordText, ""); err != nil {
+		return err
+	}
+	for _, part := range []string{"header", "footer"} {
+		for _, name := range o.partsMatching("word/"+part, ".xml") {
+			if err := o.paragraphs(name, wordText, part+" "+partNumber(name, "word/"+part, ".xml")+", "); err != nil {
+				return err
+			}
+		}
+	}
+	for _, part := range []string{"footnotes", "endnotes"} {
+		if err := o.paragraphs("word/"+part+".xml", wordText, part[:len(part)-1]+" "); err != nil {
+			return err
+		}
+	}
+
+	comments := textStyle{
+		units:    map[string]bool{"comment": true},
+		text:     wordText.text,
+		breaks:   wordText.breaks,
+		newlines: map[string]bool{"p": true},
+	}
+	err := o.readText("word/comments.xml", comments, func(unit xml.StartElement, text string) {
+		field := "comment " + attr(unit, "id")
+		o.add(text, field, "")
+		o.add(attr(unit, "author"), field+" author", "author")
+	})
+	if err != nil {
+		return err
+	}
+
+	return o.metadata("docProps/core.xml", "docProps/app.xml")
+}
+
+// xlsx reads an Excel workbook.
+func (o *officeDocument) xlsx() error {
+	shared := make([]string, 0)
+	err := o.readText("xl/sharedStrings.xml", textStyle{
+		units: map[string]bool{"si": true},
+		text:  map[string]bool{"t": true},
+	}, func(_ xml.StartElement, text string) {
+		shared = append(shared, text)
+	})
+	if err != nil {
+		return err
+	}
+
+	rels, err := o.relationships("xl/workbook.xml")
+	if err != nil {
+		return err
+	}
+	targets := make(map[string]string)
+	for _, rel := range rels {
+		targets[rel.ID] = rel.Target
+	}
+
+	sheets := make([]xml.StartElement, 0)
+	err = o.readText("xl/workbook.xml", textStyle{units: map[string]bool{"sheet": true}}, func(unit xml.StartElement, _ string) {
+		sheets = append(sheets, unit)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, sheet := range sheets {
+		name, part := attr(sheet, "name"), targets[attr(sheet, "id")]
+		if part == "" {
+			continue
+		}
+		if err := o.worksheet(part, name, shared); err != nil {
+			return err
+		}
+	}
+
+	return o.metadata("docProps/core.xml", "docProps/app.xml")
+}
+
+// worksheet reads the cells and comments of a worksheet. Text in the first
+// row is taken as column headers and hints the cells below.
+func (o *officeDocument) worksheet(part, sheet string, shared []string) error {
+	headers := make(map[string]string)
+	cells := textStyle{
+		units: map[string]bool{"c": true},
+		text:  map[string]bool{"v": true, "t": true},
+	}
+	err := o.readText(part, cells, func(cell xml.StartElement, text string) {
+		ref := attr(cell, "r")
+		if attr(cell, "t") == "s" {
+			i, err := strconv.Atoi(strings.TrimSpace(text))
+			if err != nil || i < 0 || i >= len(shared) {
+				return
+			}
+			text = shared[i]
+		}
+
+		column, row := splitCellRef(ref)
+		if row == "1" {
+			headers[column] = text
+		}
+		o.add(text, sheet+"!"+ref, headers[column])
+	})
+	if err != nil {
+		return err
+	}
+
+	rels, err := o.relationships(part)
+	if err != nil {
+		return err
+	}
+	for _, rel := range rels {
+		if !strings.HasSuffix(rel.Type, "/comments") {
+			continue
+		}
+		authors := make([]string, 0)
+		err := o.readText(rel.Target, textStyle{units: map[string]bool{"author": true}}, func(_ xml.StartElement, text string) {
+			authors = append(authors, text)
+		})
+		if err != nil {
+			return err
+		}
+		comments := textStyle{
+			units: map[string]bool{"comment": true},
+			text:  map[string]bool{"t": true},
+		}
+		err = o.readText(rel.Target, comments, func(comment xml.StartElement, text string) {
+			field := sheet + "!" + attr(comment, "ref") + " comment"
+			o.add(text, field, "")
+			if i, err := strconv.Atoi(attr(comment, "authorId")); err == nil && i >= 0 && i < len(authors) {
+				o.add(authors[i], field+" author", "author")
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitCellRef splits a cell reference such as "B7" into its column and
+// row.
+func splitCellRef(ref string) (column, row string) {
+	i := strings.IndexFunc(ref, func(r rune) bool { return r >= '0' && r <= '9' })
+	if i < 0 {
+		return ref, ""
+	}
+	return ref[:i], ref[i:]
+}
+
+// drawingText is the text layout of DrawingML, used by PowerPoint.
+var drawingText = textStyle{
+	units:  map[string]bool{"p": true},
+	text:   map[string]bool{"t": true},
+	breaks: map[string]string{"br": "\n"},
+}
+
+// pptx reads a PowerPoint presentation.
+func (o *officeDocument) pptx() error {
+	for _, part := range []struct{ prefix, label string }{
+		{"ppt/slides/slide", "slide "},
+		{"ppt/notesSlides/notesSlide", "notes "},
+	} {
+		for _, name := range o.partsMatching(part.prefix, ".xml") {
+			if err := o.paragraphs(name, drawingText, part.label+partNumber(name, part.prefix, ".xml")+", "); err != nil {
+				return err
+			}
+		}
+	}
+
+	authors := make(map[string]string)
+	err := o.readText("ppt/commentAuthors.xml", textStyle{units: map[string]bool{"cmAuthor": true}}, func(author xml.StartElement, _ string) {
+		authors[attr(author, "id")] = attr(author, "name")
+		o.add(attr(author, "name"), "comment author", "author")
+	})
+	if err != nil {
+		return err
+	}
+	comments := textStyle{
+		units: map[string]bool{"cm": true},
+		text:  map[string]bool{"text": true},
+	}
+	for _, name := range o.partsMatching("ppt/comments/comment", ".xml") {
+		n := 0
+		err := o.readText(name, comments, func(_ xml.StartElement, text string) {
+			n++
+			o.add(text, "comments "+partNumber(name, "ppt/comments/comment", ".xml")+", comment "+strconv.Itoa(n), "")
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return o.metadata("docProps/core.xml", "docProps/app.xml")
+}
+
+// odfText is the text layout of OpenDocument. Annotations name their author
+// in a dc:creator element.
+var odfText = textStyle{
+	units:  map[string]bool{"p": true, "h": true, "creator": true},
+	breaks: map[string]string{"tab": "\t", "line-break": "\n", "s": " "},
+}
+
+// odfKind returns the kind of an OpenDocument file, given by its mimetype
+// part, or "" for other types.
+func (o *officeDocument) odfKind() string {
+	rc, err := o.open("mimetype")
+	if err != nil {
+		return ""
+	}
+	defer rc.Close()
+
+	mimetype, _ := io.ReadAll(io.LimitReader(rc, 128))
+	switch strings.TrimSpace(string(mimetype)) {
+	case "application/vnd.oasis.opendocument.text":
+		return KindODT
+	case "application/vnd.oasis.opendocument.spreadsheet":
+		return KindODS
+	case "application/vnd.oasis.opendocument.presentation":
+		return KindODP
+	}
+	return ""
+}
+
+// odf reads an OpenDocument text document, spreadsheet or presentation.
+// The cells of a spreadsheet are named by sheet and cell reference.
+func (o *officeDocument) odf(kind string) error {
+	if kind == KindODS {
+		if err := o.odsCells("content.xml"); err != nil {
+			return err
+		}
+	}
+	for _, part := range []struct{ name, prefix string }{
+		{"content.xml", ""},
+		{"styles.xml", "header or footer "},
+	} {
+		if kind == KindODS && part.name == "content.xml" {
+			continue
+		}
+		n := 0
+		err := o.readText(part.name, odfText, func(unit xml.StartElement, text string) {
+			if unit.Name.Local == "creator" {
+				o.add(text, "comment author", "author")
+				return
+			}
+			n++
+			o.add(text, part.prefix+"paragraph "+strconv.Itoa(n), "")
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return o.metadata("meta.xml")
+}
+
+// odsCells reads the cells and cell comments of an OpenDocument
+// spreadsheet, named by sheet and cell reference as in "Sheet1!B7". Text in
+// the first row is taken as column headers and hints the cells below. Rows
+// and cells may stand for a run of repeated ones; such a run is read once,
+// named by its first cell, so that a small file cannot repeat a value
+// millions of times.
+func (o *officeDocument) odsCells(name string) error {
+	rc, err := o.open(name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	var (
+		sheet              string
+		row, nextRow       int
+		column, nextColumn int
+		headers            map[int]string
+		inCell, inComment  bool
+		inAuthor           bool // In the author of a comment
+		commentParagraphs  int  // A comment also holds its date, outside paragraphs
+		text, comment      strings.Builder
+		author             strings.Builder
+	)
+	// target returns where character data in the current element goes
+	target := func() *strings.Builder {
+		switch {
+		case inAuthor:
+			return &author
+		case inComment && commentParagraphs > 0:
+			return &comment
+		case inComment:
+			return nil
+		case inCell:
+			return &text
+		}
+		return nil
+	}
+
+	decoder := xml.NewDecoder(rc)
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			switch local := token.Name.Local; local {
+			case "table":
+				sheet, nextRow, headers = attr(token, "name"), 1, make(map[int]string)
+			case "table-row":
+				row, nextColumn = nextRow, 1
+				nextRow += odsRepeat(token, "number-rows-repeated")
+			case "table-cell", "covered-table-cell":
+				column = nextColumn
+				nextColumn += odsRepeat(token, "number-columns-repeated")
+				inCell = true
+				text.Reset()
+				comment.Reset()
+				author.Reset()
+			case "annotation":
+				inComment = inCell
+			case "creator":
+				inAuthor = inComment
+			case "p", "h":
+				if inComment {
+					commentParagraphs++
+				}
+				if b := target(); b != nil && b.Len() > 0 {
+					b.WriteByte('\n')
+				}
+			default:
+				if b := target(); b != nil {
+					b.WriteString(odfText.breaks[local])
+				}
+			}
+		case xml.EndElement:
+			switch token.Name.Local {
+			case "annotation":
+				inComment, commentParagraphs = false, 0
+			case "p", "h":
+				if inComment && commentParagraphs > 0 {
+					commentParagraphs--
+				}
+			case "creator":
+				inAuthor = false
+			case "table-cell", "covered-table-cell":
+				if !inCell {
+					continue
+				}
+				inCell = false
+				ref := columnName(column) + strconv.Itoa(row)
+				if row == 1 {
+					headers[column] = text.String()
+				}
+				o.add(text.String(), sheet+"!"+ref, headers[column])
+				o.add(comment.String(), sheet+"!"+ref+" comment", "")
+				o.add(author.String(), sheet+"!"+ref+" comment author", "author")
+			}
+		case xml.CharData:
+			if b := target(); b != nil {
+				b.Write(token)
+			}
+		}
+	}
+}
+
+// odsRepeat returns the number of rows or cells an OpenDocument table
+// element stands for, given by the named attribute.
+func odsRepeat(element xml.StartElement, name string) int {
+	n, err := strconv.Atoi(attr(element, name))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// columnName returns the letters naming a spreadsheet column, counting from
+// 1, as in "A", "Z" and "AA".
+func columnName(n int) string {
+	name := ""
+	for ; n > 0; n = (n - 1) / 26 {
+		name = string(rune('A'+(n-1)%26)) + name
+	}
+	return name
+}
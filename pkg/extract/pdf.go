@@ -0,0 +1,992 @@
+package extract
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/hallucinaut/privacyguard/pkg/scan"
+)
+
+// PDF returns the text of each page of a PDF file, named "page 1",
+// "page 2" and so on, followed by the document information that may hold
+// names and email addresses, such as the author, named like
+// "metadata: author". Page text is read from the text-showing operators of
+// the page's content streams, using the fonts' ToUnicode maps where present;
+// text drawn as images is not seen. Encrypted files are not supported, nor
+// are files whose page content decompresses to more than maxDocumentSize.
+func PDF(data []byte) ([]scan.Value, error) {
+	f, err := parsePDF(data)
+	if err != nil {
+		return nil, err
+	}
+	if f.trailer["Encrypt"] != nil {
+		return nil, errors.New("encrypted PDF")
+	}
+
+	values := make([]scan.Value, 0)
+	budget := &documentBudget{size: maxDocumentSize}
+	for i, page := range f.pages() {
+		text, err := f.pageText(page, budget)
+		if err != nil {
+			return nil, err
+		}
+		if text = strings.TrimSpace(text); text != "" {
+			values = append(values, scan.Value{Text: text, Field: "page " + strconv.Itoa(i+1)})
+		}
+	}
+
+	info := pdfDictOf(f.resolve(f.trailer["Info"]))
+	for _, field := range pdfInfoFields {
+		if s, ok := f.resolve(info[field.key]).(pdfString); ok {
+			if text := pdfTextString(s); strings.TrimSpace(text) != "" {
+				values = append(values, scan.Value{Text: text, Field: "metadata: " + field.label, Hint: field.label})
+			}
+		}
+	}
+	return values, nil
+}
+
+// pdfInfoFields names the entries of the document information dictionary
+// that may hold PII.
+var pdfInfoFields = []struct {
+	key   pdfName
+	label string
+}{
+	{"Author", "author"},
+	{"Creator", "creator"},
+	{"Title", "title"},
+	{"Subject", "subject"},
+	{"Keywords", "keywords"},
+}
+
+// PDF objects, as read by pdfLexer. Numbers are float64, booleans bool and
+// null nil; keywords such as operators are pdfKeyword.
+type (
+	pdfName    string
+	pdfString  []byte
+	pdfKeyword string
+	pdfArray   []any
+	pdfDict    map[pdfName]any
+	pdfRef     struct{ num, gen int }
+	pdfStream  struct {
+		dict pdfDict
+		raw  []byte
+	}
+)
+
+// pdfDictOf returns the dictionary of a dictionary or stream, or nil.
+func pdfDictOf(v any) pdfDict {
+	switch v := v.(type) {
+	case pdfDict:
+		return v
+	case *pdfStream:
+		return v.dict
+	}
+	return nil
+}
+
+// pdfNumber returns a number, or 0 for anything else.
+func pdfNumber(v any) float64 {
+	n, _ := v.(float64)
+	return n
+}
+
+// pdfLexer reads the tokens and objects of PDF syntax.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFSpace(b byte) bool {
+	return b == 0 || b == '\t' || b == '\n' || b == '\f' || b == '\r' || b == ' '
+}
+
+func isPDFDelimiter(b byte) bool {
+	return strings.IndexByte("()<>[]{}/%", b) >= 0
+}
+
+// skipSpace skips whitespace and comments.
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		switch b := l.data[l.pos]; {
+		case isPDFSpace(b):
+			l.pos++
+		case b == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// token reads a name, string, number, keyword or delimiter such as "<<",
+// returned as a pdfKeyword. It returns io.EOF at the end of the data.
+func (l *pdfLexer) token() (any, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, io.EOF
+	}
+
+	start := l.pos
+	switch b := l.data[l.pos]; b {
+	case '/':
+		l.pos++
+		var name []byte
+		for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+			c := l.data[l.pos]
+			if c == '#' && l.pos+2 < len(l.data) {
+				if decoded, err := hex.DecodeString(string(l.data[l.pos+1 : l.pos+3])); err == nil {
+					name = append(name, decoded[0])
+					l.pos += 3
+					continue
+				}
+			}
+			name = append(name, c)
+			l.pos++
+		}
+		return pdfName(name), nil
+	case '(':
+		return l.literalString()
+	case '<':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '<' {
+			l.pos += 2
+			return pdfKeyword("<<"), nil
+		}
+		return l.hexString()
+	case '>':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '>' {
+			l.pos += 2
+			return pdfKeyword(">>"), nil
+		}
+		l.pos++
+		return nil, errors.New("unexpected '>'")
+	case '[', ']', '{', '}':
+		l.pos++
+		return pdfKeyword(b), nil
+	case ')':
+		l.pos++
+		return nil, errors.New("unexpected ')'")
+	}
+
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	word := string(l.data[start:l.pos])
+	if n, err := strconv.ParseFloat(word, 64); err == nil && strings.IndexAny(word[:1], "+-.0123456789") == 0 {
+		return n, nil
+	}
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	return pdfKeyword(word), nil
+}
+
+// literalString reads a string in parentheses, resolving escapes.
+func (l *pdfLexer) literalString() (pdfString, error) {
+	l.pos++
+	s := make([]byte, 0)
+	for depth := 0; l.pos < len(l.data); {
+		b := l.data[l.pos]
+		l.pos++
+		switch b {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return s, nil
+			}
+			depth--
+		case '\r':
+			// Line ends within strings read as a single newline
+			if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+				l.pos++
+			}
+			b = '\n'
+		case '\\':
+			if l.pos >= len(l.data) {
+				continue
+			}
+			b = l.data[l.pos]
+			l.pos++
+			switch b {
+			case 'n':
+				b = '\n'
+			case 'r':
+				b = '\r'
+			case 't':
+				b = '\t'
+			case 'b':
+				b = '\b'
+			case 'f':
+				b = '\f'
+			case '\r', '\n':
+				if b == '\r' && l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			default:
+				if b >= '0' && b <= '7' {
+					code := int(b - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						code = code*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					b = byte(code)
+				}
+			}
+		}
+		s = append(s, b)
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+// hexString reads a string of hexadecimal digits in angle brackets.
+func (l *pdfLexer) hexString() (pdfString, error) {
+	l.pos++
+	end := bytes.IndexByte(l.data[l.pos:], '>')
+	if end < 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	s := decodeHex(l.data[l.pos : l.pos+end])
+	l.pos += end + 1
+	return s, nil
+}
+
+// decodeHex decodes hexadecimal digits, ignoring anything else; a missing
+// last digit is taken to be 0.
+func decodeHex(digits []byte) []byte {
+	clean := make([]byte, 0, len(digits)+1)
+	for _, b := range digits {
+		if b >= '0' && b <= '9' || b >= 'a' && b <= 'f' || b >= 'A' && b <= 'F' {
+			clean = append(clean, b)
+		}
+	}
+	if len(clean)%2 == 1 {
+		clean = append(clean, '0')
+	}
+	s := make([]byte, len(clean)/2)
+	hex.Decode(s, clean)
+	return s
+}
+
+// object reads an object: an array or dictionary with everything in it, an
+// indirect reference such as "12 0 R", or a single token.
+func (l *pdfLexer) object() (any, error) {
+	return l.objectAt(0)
+}
+
+func (l *pdfLexer) objectAt(depth int) (any, error) {
+	if depth > 64 {
+		return nil, errors.New("objects nested too deeply")
+	}
+	token, err := l.token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case pdfKeyword("<<"):
+		dict := make(pdfDict)
+		for {
+			key, err := l.objectAt(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if key == pdfKeyword(">>") {
+				return dict, nil
+			}
+			value, err := l.objectAt(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if name, ok := key.(pdfName); ok {
+				dict[name] = value
+			}
+		}
+	case pdfKeyword("["):
+		array := make(pdfArray, 0)
+		for {
+			item, err := l.objectAt(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if item == pdfKeyword("]") {
+				return array, nil
+			}
+			array = append(array, item)
+		}
+	}
+
+	if num, ok := token.(float64); ok && num == float64(int(num)) {
+		// A number may start a reference
+		start := l.pos
+		gen, err1 := l.token()
+		r, err2 := l.token()
+		if g, ok := gen.(float64); ok && err1 == nil && err2 == nil && r == pdfKeyword("R") {
+			return pdfRef{int(num), int(g)}, nil
+		}
+		l.pos = start
+	}
+	return token, nil
+}
+
+// pdfFile holds the objects of a PDF file.
+type pdfFile struct {
+	objects map[int]any
+	trailer pdfDict
+}
+
+var (
+	pdfObjectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	pdfTrailer      = regexp.MustCompile(`trailer\s*<<`)
+)
+
+// parsePDF reads the objects of a PDF file. Rather than trusting the cross
+// reference table, which is often damaged, it looks for every object in the
+// file; where an object was redefined by an incremental update, the last
+// definition wins. Objects packed into object streams are unpacked.
+func parsePDF(data []byte) (*pdfFile, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return nil, errors.New("not a PDF file")
+	}
+	f := &pdfFile{objects: make(map[int]any), trailer: make(pdfDict)}
+
+	type trailer struct {
+		offset int
+		dict   pdfDict
+	}
+	trailers := make([]trailer, 0)
+
+	cursor := 0
+	for _, m := range pdfObjectHeader.FindAllSubmatchIndex(data, -1) {
+		if m[0] < cursor {
+			// Inside the data of a stream
+			continue
+		}
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		l := &pdfLexer{data: data, pos: m[1]}
+		obj, err := l.object()
+		if err != nil {
+			continue
+		}
+
+		end := l.pos
+		if dict, ok := obj.(pdfDict); ok {
+			if next, err := l.token(); err == nil && next == pdfKeyword("stream") {
+				stream := &pdfStream{dict: dict}
+				stream.raw, end = streamData(data, l.pos, dict)
+				obj = stream
+			}
+			if dict["Type"] == pdfName("XRef") {
+				trailers = append(trailers, trailer{m[0], dict})
+			}
+		}
+		f.objects[num] = obj
+		cursor = end
+	}
+
+	for _, m := range pdfTrailer.FindAllIndex(data, -1) {
+		l := &pdfLexer{data: data, pos: m[1] - 2}
+		if dict, err := l.object(); err == nil {
+			if dict, ok := dict.(pdfDict); ok {
+				trailers = append(trailers, trailer{m[0], dict})
+			}
+		}
+	}
+	sort.Slice(trailers, func(i, j int) bool { return trailers[i].offset < trailers[j].offset })
+	for _, t := range trailers {
+		for _, key := range []pdfName{"Root", "Info", "Encrypt"} {
+			if value, ok := t.dict[key]; ok {
+				f.trailer[key] = value
+			}
+		}
+	}
+
+	if len(f.objects) == 0 {
+		return nil, errors.New("no PDF objects found")
+	}
+	f.unpackObjectStreams()
+	return f, nil
+}
+
+// streamData returns the data of a stream whose "stream" keyword ends at
+// start, and the offset after it. The stated length is used if "endstream"
+// follows it; otherwise the data runs to the next "endstream".
+func streamData(data []byte, start int, dict pdfDict) ([]byte, int) {
+	if start < len(data) && data[start] == '\r' {
+		start++
+	}
+	if start < len(data) && data[start] == '\n' {
+		start++
+	}
+
+	if n, ok := dict["Length"].(float64); ok && n >= 0 && start+int(n) <= len(data) {
+		end := start + int(n)
+		rest := bytes.TrimLeft(data[end:], "\x00\t\n\f\r ")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			return data[start:end], end
+		}
+	}
+
+	i := bytes.Index(data[start:], []byte("endstream"))
+	if i < 0 {
+		return data[start:], len(data)
+	}
+	end := start + i
+	raw := bytes.TrimSuffix(bytes.TrimSuffix(data[start:end], []byte("\n")), []byte("\r"))
+	return raw, end
+}
+
+// unpackObjectStreams adds the objects packed into object streams, unless
+// they are also defined directly.
+func (f *pdfFile) unpackObjectStreams() {
+	streams := make([]*pdfStream, 0)
+	for _, obj := range f.objects {
+		if stream, ok := obj.(*pdfStream); ok && stream.dict["Type"] == pdfName("ObjStm") {
+			streams = append(streams, stream)
+		}
+	}
+
+	for _, stream := range streams {
+		data, err := f.decode(stream)
+		if err != nil {
+			continue
+		}
+		n, first := int(pdfNumber(f.resolve(stream.dict["N"]))), int(pdfNumber(f.resolve(stream.dict["First"])))
+		header := &pdfLexer{data: data}
+		for i := 0; i < n; i++ {
+			num, err1 := header.token()
+			offset, err2 := header.token()
+			if err1 != nil || err2 != nil {
+				break
+			}
+			if _, defined := f.objects[int(pdfNumber(num))]; defined {
+				continue
+			}
+			l := &pdfLexer{data: data, pos: first + int(pdfNumber(offset))}
+			if l.pos < 0 || l.pos >= len(data) {
+				continue
+			}
+			if obj, err := l.object(); err == nil {
+				f.objects[int(pdfNumber(num))] = obj
+			}
+		}
+	}
+}
+
+// resolve follows indirect references.
+func (f *pdfFile) resolve(v any) any {
+	for i := 0; i < 32; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = f.objects[ref.num]
+	}
+	return nil
+}
+
+// pdfList returns a name or array of names, such as a stream's filters, as a
+// slice.
+func (f *pdfFile) pdfList(v any) []any {
+	switch v := f.resolve(v).(type) {
+	case nil:
+		return nil
+	case pdfArray:
+		list := make([]any, len(v))
+		for i, item := range v {
+			list[i] = f.resolve(item)
+		}
+		return list
+	default:
+		return []any{v}
+	}
+}
+
+// decode returns the data of a stream with its filters undone.
+func (f *pdfFile) decode(stream *pdfStream) ([]byte, error) {
+	data := stream.raw
+	params := f.pdfList(stream.dict["DecodeParms"])
+	for i, filter := range f.pdfList(stream.dict["Filter"]) {
+		var err error
+		switch filter {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			data, err = inflate(data)
+			if err == nil && i < len(params) {
+				data, err = unpredict(data, pdfDictOf(params[i]))
+			}
+		case pdfName("ASCIIHexDecode"), pdfName("AHx"):
+			if end := bytes.IndexByte(data, '>'); end >= 0 {
+				data = data[:end]
+			}
+			data = decodeHex(data)
+		case pdfName("ASCII85Decode"), pdfName("A85"):
+			data = bytes.TrimPrefix(bytes.TrimSpace(data), []byte("<~"))
+			if end := bytes.Index(data, []byte("~>")); end >= 0 {
+				data = data[:end]
+			}
+			data, err = io.ReadAll(io.LimitReader(ascii85.NewDecoder(bytes.NewReader(data)), maxPartSize))
+		default:
+			return nil, fmt.Errorf("unsupported filter %v", filter)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// inflate decompresses zlib data, up to maxPartSize bytes. Raw deflate
+// data is accepted too, and the data read before a truncated or corrupt end
+// is kept, as readers of PDF commonly do.
+func inflate(data []byte) ([]byte, error) {
+	var r io.ReadCloser
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		r = flate.NewReader(bytes.NewReader(data))
+	}
+	defer r.Close()
+
+	out, err := io.ReadAll(io.LimitReader(r, maxPartSize+1))
+	if len(out) > maxPartSize {
+		return nil, errPartTooLarge
+	}
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	return out, nil
+}
+
+// unpredict undoes the PNG predictors that may be applied before Flate
+// compression. The parameters are checked before use, so that a row is never
+// wider than the data.
+func unpredict(data []byte, params pdfDict) ([]byte, error) {
+	if pdfNumber(params["Predictor"]) < 10 {
+		return data, nil
+	}
+	colors, bits, columns := 1.0, 8.0, 1.0
+	if n := pdfNumber(params["Colors"]); n > 0 {
+		colors = n
+	}
+	if n := pdfNumber(params["BitsPerComponent"]); n > 0 {
+		bits = n
+	}
+	if n := pdfNumber(params["Columns"]); n > 0 {
+		columns = n
+	}
+	if colors > 32 || colors != float64(int(colors)) {
+		return nil, fmt.Errorf("invalid predictor colors %v", colors)
+	}
+	switch bits {
+	case 1, 2, 4, 8, 16:
+	default:
+		return nil, fmt.Errorf("invalid predictor bits per component %v", bits)
+	}
+	if (colors*bits*columns+7)/8 > float64(len(data)) {
+		return nil, fmt.Errorf("predictor row of %v columns is wider than the data", columns)
+	}
+	bpp := (int(colors)*int(bits) + 7) / 8
+	width := (int(colors)*int(bits)*int(columns) + 7) / 8
+
+	out := make([]byte, 0, len(data))
+	previous := make([]byte, width)
+	for len(data) > width {
+		filter, row := data[0], append([]byte(nil), data[1:1+width]...)
+		data = data[1+width:]
+		for i := range row {
+			var left, upLeft byte
+			if i >= bpp {
+				left, upLeft = row[i-bpp], previous[i-bpp]
+			}
+			up := previous[i]
+			switch filter {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			}
+		}
+		out = append(out, row...)
+		previous = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// pdfPage is a page with the resources it uses, which may be inherited.
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+// pages returns the pages of the document in order.
+func (f *pdfFile) pages() []pdfPage {
+	pages := make([]pdfPage, 0)
+	visited := make(map[pdfRef]bool)
+
+	var walk func(node any, resources pdfDict, depth int)
+	walk = func(node any, resources pdfDict, depth int) {
+		if ref, ok := node.(pdfRef); ok {
+			if visited[ref] {
+				return
+			}
+			visited[ref] = true
+		}
+		dict := pdfDictOf(f.resolve(node))
+		if dict == nil || depth > 64 {
+			return
+		}
+		if r := pdfDictOf(f.resolve(dict["Resources"])); r != nil {
+			resources = r
+		}
+		kids, isTree := f.resolve(dict["Kids"]).(pdfArray)
+		if dict["Type"] == pdfName("Page") || !isTree {
+			pages = append(pages, pdfPage{dict: dict, resources: resources})
+			return
+		}
+		for _, kid := range kids {
+			walk(kid, resources, depth+1)
+		}
+	}
+
+	root := pdfDictOf(f.resolve(f.trailer["Root"]))
+	if root == nil {
+		for _, obj := range f.objects {
+			if dict := pdfDictOf(obj); dict["Type"] == pdfName("Catalog") {
+				root = dict
+				break
+			}
+		}
+	}
+	if root != nil {
+		walk(root["Pages"], nil, 0)
+	}
+	return pages
+}
+
+// pdfFont maps the character codes of a font to text.
+type pdfFont struct {
+	width   int // Bytes per character code
+	unicode map[uint32]string
+}
+
+// fonts returns the fonts of a page's resources by name.
+func (f *pdfFile) fonts(resources pdfDict) map[pdfName]*pdfFont {
+	fonts := make(map[pdfName]*pdfFont)
+	for name, ref := range pdfDictOf(f.resolve(resources["Font"])) {
+		dict := pdfDictOf(f.resolve(ref))
+		font := &pdfFont{width: 1}
+		if dict["Subtype"] == pdfName("Type0") {
+			font.width = 2
+		}
+		if stream, ok := f.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+			if data, err := f.decode(stream); err == nil {
+				var width int
+				font.unicode, width = parseCMap(data)
+				if width > 0 {
+					font.width = width
+				}
+			}
+		}
+		fonts[name] = font
+	}
+	return fonts
+}
+
+// parseCMap reads the character mappings of a ToUnicode CMap, and the
+// length of its codes in bytes if given.
+func parseCMap(data []byte) (map[uint32]string, int) {
+	unicode := make(map[uint32]string)
+	width := 0
+
+	l := &pdfLexer{data: data}
+	operands := make([]any, 0)
+	for {
+		obj, err := l.object()
+		if err != nil {
+			break
+		}
+		keyword, ok := obj.(pdfKeyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+
+		switch keyword {
+		case "endcodespacerange":
+			if len(operands) > 0 {
+				if low, ok := operands[0].(pdfString); ok {
+					width = len(low)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					unicode[pdfCode(src)] = utf16String(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				low, ok1 := operands[i].(pdfString)
+				high, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 || pdfCode(high) < pdfCode(low) || pdfCode(high)-pdfCode(low) > 0xffff {
+					continue
+				}
+				for code := pdfCode(low); code <= pdfCode(high); code++ {
+					n := code - pdfCode(low)
+					switch dst := operands[i+2].(type) {
+					case pdfString:
+						units := utf16Units(dst)
+						if len(units) > 0 {
+							units[len(units)-1] += uint16(n)
+						}
+						unicode[code] = string(utf16.Decode(units))
+					case pdfArray:
+						if int(n) < len(dst) {
+							if s, ok := dst[n].(pdfString); ok {
+								unicode[code] = utf16String(s)
+							}
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	return unicode, width
+}
+
+// pdfCode returns the character code in a string of bytes.
+func pdfCode(s []byte) uint32 {
+	var code uint32
+	for _, b := range s {
+		code = code<<8 | uint32(b)
+	}
+	return code
+}
+
+func utf16Units(s []byte) []uint16 {
+	units := make([]uint16, len(s)/2)
+	for i := range units {
+		units[i] = uint16(s[2*i])<<8 | uint16(s[2*i+1])
+	}
+	return units
+}
+
+func utf16String(s []byte) string {
+	return string(utf16.Decode(utf16Units(s)))
+}
+
+// pdfTextString decodes a text string outside a content stream, such as a
+// metadata field: UTF-16 with a byte order mark, or else a single-byte
+// encoding read as Latin-1.
+func pdfTextString(s pdfString) string {
+	if bytes.HasPrefix(s, []byte{0xfe, 0xff}) {
+		return utf16String(s[2:])
+	}
+	if bytes.HasPrefix(s, []byte{0xef, 0xbb, 0xbf}) {
+		return string(s[3:])
+	}
+	return latin1(s)
+}
+
+func latin1(s []byte) string {
+	runes := make([]rune, len(s))
+	for i, b := range s {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// pageText returns the text shown on a page, with line breaks where the
+// text moves to a new line. The page's decoded content streams are counted
+// against the document's budget.
+func (f *pdfFile) pageText(page pdfPage, budget *documentBudget) (string, error) {
+	var content []byte
+	for _, item := range f.pdfList(page.dict["Contents"]) {
+		if stream, ok := item.(*pdfStream); ok {
+			if data, err := f.decode(stream); err == nil {
+				if err := budget.spend(len(data)); err != nil {
+					return "", err
+				}
+				content = append(append(content, data...), '\n')
+			}
+		}
+	}
+
+	t := &pdfText{fonts: f.fonts(page.resources)}
+	t.run(content)
+	return t.out.String(), nil
+}
+
+// pdfText collects the text shown by a content stream.
+type pdfText struct {
+	fonts map[pdfName]*pdfFont
+	font  *pdfFont
+	out   strings.Builder
+	y     float64 // Vertical position set by the last Tm operator
+}
+
+// Kerning in a TJ array wider than this, in thousandths of an em, is taken
+// to separate words.
+const pdfWordGap = -180
+
+// run interprets the text operators of a content stream.
+func (t *pdfText) run(content []byte) {
+	l := &pdfLexer{data: content}
+	operands := make([]any, 0)
+	for {
+		obj, err := l.object()
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			// Skip a stray delimiter
+			operands = operands[:0]
+			continue
+		}
+		op, ok := obj.(pdfKeyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+
+		n := len(operands)
+		switch op {
+		case "ET", "T*":
+			t.newline()
+		case "Tf":
+			if n >= 2 {
+				name, _ := operands[n-2].(pdfName)
+				t.font = t.fonts[name]
+			}
+		case "Td", "TD":
+			if n >= 2 && pdfNumber(operands[n-1]) != 0 {
+				t.newline()
+			} else {
+				t.space()
+			}
+		case "Tm":
+			if n >= 6 {
+				if y := pdfNumber(operands[n-1]); y != t.y {
+					t.newline()
+					t.y = y
+				} else {
+					t.space()
+				}
+			}
+		case "Tj":
+			if n >= 1 {
+				t.show(operands[n-1])
+			}
+		case "'", "\"":
+			t.newline()
+			if n >= 1 {
+				t.show(operands[n-1])
+			}
+		case "TJ":
+			if n >= 1 {
+				array, _ := operands[n-1].(pdfArray)
+				for _, item := range array {
+					if gap, ok := item.(float64); ok && gap < pdfWordGap {
+						t.space()
+					} else {
+						t.show(item)
+					}
+				}
+			}
+		case "ID":
+			// Inline image data runs to "EI"
+			l.pos = inlineImageEnd(content, l.pos)
+		}
+		operands = operands[:0]
+	}
+}
+
+// inlineImageEnd returns the offset after the "EI" that ends inline image
+// data starting at start.
+func inlineImageEnd(content []byte, start int) int {
+	for i := start; i+2 <= len(content); i++ {
+		if content[i] == 'E' && content[i+1] == 'I' && i > start && isPDFSpace(content[i-1]) &&
+			(i+2 == len(content) || isPDFSpace(content[i+2])) {
+			return i + 2
+		}
+	}
+	return len(content)
+}
+
+// show writes a string shown in the current font.
+func (t *pdfText) show(v any) {
+	s, ok := v.(pdfString)
+	if !ok {
+		return
+	}
+	font := t.font
+	if font == nil {
+		t.out.WriteString(latin1(s))
+		return
+	}
+	for i := 0; i+font.width <= len(s); i += font.width {
+		code := pdfCode(s[i : i+font.width])
+		if text, ok := font.unicode[code]; ok {
+			t.out.WriteString(text)
+		} else if font.width == 1 {
+			t.out.WriteRune(rune(code))
+		}
+	}
+}
+
+// space separates words, unless the text already ends with a separator.
+func (t *pdfText) space() {
+	if s := t.out.String(); s != "" && !strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\n") {
+		t.out.WriteByte(' ')
+	}
+}
+
+// newline ends a line, unless the text already ends with one.
+func (t *pdfText) newline() {
+	if s := t.out.String(); s != "" && !strings.HasSuffix(s, "\n") {
+		t.out.WriteByte('\n')
+	}
+}
@@ -0,0 +1,127 @@
+package extract
+
+import (
+	"io"
+	"strings"
+
+	"github.com/hallucinaut/privacyguard/pkg/scan"
+	"gopkg.in/yaml.v3"
+)
+
+// YAML returns the scalars in YAML content, each named by its JSON path and
+// hinted by its key as in JSON. Mapping keys are named by the path of their
+// mapping, and each document of a multi-document stream starts again at "$".
+// Scalars are returned as written where possible: block scalars keep their
+// indentation and plain and quoted scalars are taken from the source, so that
+// positions within them stay exact; a scalar with escapes or folded lines is
+// returned decoded, positioned at its start.
+func YAML(content string) ([]scan.Value, error) {
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	y := &yamlDocument{index: newLineIndex(content), values: make([]scan.Value, 0)}
+
+	for {
+		var document yaml.Node
+		if err := decoder.Decode(&document); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		y.walk(&document, "$", "", -1)
+	}
+	return y.values, nil
+}
+
+// yamlDocument collects the scalars of YAML documents.
+type yamlDocument struct {
+	index  *lineIndex
+	values []scan.Value
+}
+
+// walk collects the scalars in node, which is stored under the key hint.
+// Block scalars in node are indented further than indent, the column of the
+// key or sequence entry holding node, counting from 0.
+func (y *yamlDocument) walk(node *yaml.Node, path, hint string, indent int) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			y.walk(child, path, hint, indent)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Kind == yaml.ScalarNode && key.Value == "<<" {
+				// Merge keys repeat the anchored mapping, which is scanned
+				// where it is defined
+				continue
+			}
+			y.walk(key, path, hint, key.Column-1)
+			y.walk(value, childPath(path, key.Value), key.Value, key.Column-1)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			y.walk(child, indexPath(path, i), hint, node.Column-1)
+		}
+	case yaml.ScalarNode:
+		y.add(node, path, hint, indent)
+	}
+}
+
+// add records a scalar that may hold PII.
+func (y *yamlDocument) add(node *yaml.Node, path, hint string, indent int) {
+	if node.Value == "" || node.ShortTag() == "!!null" || node.ShortTag() == "!!bool" {
+		return
+	}
+
+	content := y.index.content
+	start := y.index.offset(node.Line, node.Column)
+
+	switch {
+	case node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		if end := y.blockEnd(node.Line, indent); end > start {
+			start = y.index.starts[node.Line]
+			value := y.index.value(start, end, path)
+			value.Hint = hint
+			y.values = append(y.values, value)
+			return
+		}
+	case node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0:
+		start++
+	}
+
+	value := y.index.value(start, start, path)
+	value.Hint = hint
+	if strings.HasPrefix(content[start:], node.Value) {
+		value.Text = content[start : start+len(node.Value)]
+	} else {
+		value.Text = node.Value
+	}
+	y.values = append(y.values, value)
+}
+
+// blockEnd returns the end of the block scalar introduced on the given line:
+// the end of the last of the following lines indented further than indent,
+// ignoring blank lines. It returns 0 if there is no such line.
+func (y *yamlDocument) blockEnd(line, indent int) int {
+	content, starts := y.index.content, y.index.starts
+
+	end := 0
+	for i := line; i < len(starts); i++ {
+		text := content[starts[i]:]
+		if j := strings.IndexByte(text, '\n'); j >= 0 {
+			text = text[:j]
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		if indentation(text) <= indent {
+			break
+		}
+		end = starts[i] + len(strings.TrimRight(text, "\r"))
+	}
+	return end
+}
+
+// indentation returns the number of spaces starting s.
+func indentation(s string) int {
+	return len(s) - len(strings.TrimLeft(s, " "))
+}
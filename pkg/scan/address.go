@@ -0,0 +1,142 @@
+package scan
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Building blocks of the address expressions.
+const (
+	usStates    = `(?:AL|AK|AZ|AR|CA|CO|CT|DE|DC|FL|GA|HI|ID|IL|IN|IA|KS|KY|LA|ME|MD|MA|MI|MN|MS|MO|MT|NE|NV|NH|NJ|NM|NY|NC|ND|OH|OK|OR|PA|RI|SC|SD|TN|TX|UT|VT|VA|WA|WV|WI|WY|PR|GU|VI)`
+	usZIP       = `[0-9]{5}(?:-[0-9]{4})?\b`
+	caProvinces = `(?:AB|BC|MB|NB|NL|NS|NT|NU|ON|PE|QC|SK|YT)`
+	caPostal    = `\b[ABCEGHJ-NPRSTVXY][0-9][ABCEGHJ-NPRSTV-Z][ \t-]?[0-9][ABCEGHJ-NPRSTV-Z][0-9]\b`
+	ukPostcode  = `\b(?:[A-PR-UWYZ](?:[0-9]{1,2}|[A-HK-Y][0-9]{1,2}|[0-9][A-HJKPSTUW]|[A-HK-Y][0-9][ABEHMNPRVWXY])|GIR)[ \t]?[0-9][ABD-HJLNP-UW-Z]{2}\b`
+	placeName   = `[A-Z][A-Za-z.'-]*(?:[ \t]+[A-Z][A-Za-z.'-]*){0,3}`
+
+	streetSuffix = `(?i:street|st|avenue|ave|road|rd|boulevard|blvd|lane|ln|drive|dr|court|ct|place|pl|terrace|ter|way|parkway|pkwy|highway|hwy|circle|cir|square|sq|trail|trl|alley|loop|crescent|close|row|mews|gardens|grove)`
+	unit         = `(?:,?[ \t]*(?i:apt|apartment|suite|ste|unit|fl|floor|rm|room)\.?[ \t]*#?[ \t]*[A-Za-z0-9-]+|,?[ \t]*#[ \t]*[A-Za-z0-9-]+)`
+)
+
+// addressFormat is one kind of postal address. Street formats may be
+// followed by a city and postal code, which the tail expression matches
+// directly after the street so the finding covers the whole address.
+type addressFormat struct {
+	name       string
+	regex      *regexp.Regexp
+	tail       *regexp.Regexp
+	confidence float64 // Without a tail
+	withTail   float64 // Including a tail
+	// Matches without a tail are only reported next to an address keyword,
+	// as a number and a few capitalized words before a suffix such as "Way"
+	// or "Court", or a word ending in "weg" before a number, are common in
+	// prose
+	bareNeedsKeyword bool
+}
+
+// addressFormats are tried in order; later formats do not report spans that
+// overlap an earlier finding.
+var addressFormats = []addressFormat{
+	{
+		name: "US/UK street address",
+		regex: regexp.MustCompile(`\b[0-9]{1,6}[A-Za-z]?(?:-[0-9]+)?[ \t]+(?:(?:[NSEW]|North|South|East|West)\.?[ \t]+)?` +
+			`(?:[A-Z0-9][A-Za-z0-9'-]*\.?[ \t]+){1,4}` + streetSuffix + `\b\.?(?:[ \t]+(?:NE|NW|SE|SW|N|S|E|W)\b\.?)?` + unit + `?`),
+		tail: regexp.MustCompile(`^,?[ \t]*\r?\n?[ \t]*(?:` + placeName + `,?[ \t]+)?` +
+			`(?:` + usStates + `[ \t]+` + usZIP + `|(?:` + caProvinces + `[ \t]+)?` + caPostal + `|` + ukPostcode + `)`),
+		confidence:       0.6,
+		withTail:         0.85,
+		bareNeedsKeyword: true,
+	},
+	{
+		name: "German street address",
+		regex: regexp.MustCompile(`\b(?:[A-ZÄÖÜ][a-zäöüß]+(?:straße|strasse|str\.|weg|platz|allee|gasse|damm|ufer)|` +
+			`(?:[A-ZÄÖÜ][a-zäöüß]+[ \t-])+(?:Straße|Strasse|Str\.|Weg|Platz|Allee|Gasse|Ring|Damm|Ufer))[ \t]+[0-9]{1,4}[a-z]?\b`),
+		tail:             regexp.MustCompile(`^,?[ \t]*\r?\n?[ \t]*(?:D-)?[0-9]{5}[ \t]+[A-ZÄÖÜ][a-zäöüß]+(?:[ \t]+(?:am|an der|im)[ \t]+[A-ZÄÖÜ][a-zäöüß]+)?`),
+		confidence:       0.6,
+		withTail:         0.85,
+		bareNeedsKeyword: true,
+	},
+	{
+		name: "French street address",
+		regex: regexp.MustCompile(`\b[0-9]{1,4}(?:[ \t]?(?i:bis|ter))?,?[ \t]+` +
+			`(?:[Rr]ue|[Aa]venue|[Aa]v\.|[Bb]oulevard|[Bb]d|[Pp]lace|[Cc]hemin|[Aa]llée|[Ii]mpasse|[Qq]uai|[Rr]oute|[Cc]ours)` +
+			`[ \t]+[\p{L}'’ -]{2,40}`),
+		tail:             regexp.MustCompile(`^,?[ \t]*\r?\n?[ \t]*[0-9]{5}[ \t]+\p{Lu}[\p{L}'’-]+(?:[ \t]+\p{Lu}[\p{L}'’-]+)*(?:[ \t]+(?i:cedex)(?:[ \t]+[0-9]{1,2})?)?`),
+		confidence:       0.55,
+		withTail:         0.85,
+		bareNeedsKeyword: true,
+	},
+	{
+		name:       "US city, state and ZIP code",
+		regex:      regexp.MustCompile(`\b` + placeName + `,[ \t]*` + usStates + `[ \t]+` + usZIP),
+		confidence: 0.6,
+	},
+	{
+		name:       "Canadian postal code",
+		regex:      regexp.MustCompile(`(?:\b` + caProvinces + `[ \t]+)?` + caPostal),
+		confidence: 0.5,
+	},
+	{
+		name:       "UK postcode",
+		regex:      regexp.MustCompile(ukPostcode),
+		confidence: 0.5,
+	},
+}
+
+var addressKeywords = []string{"address", "addr", "street", "ship to", "shipping", "billing", "mailing", "residence", "home", "postcode", "zip", "adresse", "anschrift", "wohnhaft", "domicile"}
+
+func init() {
+	RegisterDetector("addresses", Triggered(Trigger{Digits: 1}, DetectorFunc(detectAddresses)))
+}
+
+// detectAddresses finds postal addresses in content.
+func detectAddresses(content string) []Candidate {
+	candidates := make([]Candidate, 0)
+
+	overlaps := func(start, end int) bool {
+		for _, c := range candidates {
+			if start < c.End && c.Start < end {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, format := range addressFormats {
+		for _, loc := range format.regex.FindAllStringIndex(content, -1) {
+			start, end := loc[0], loc[1]
+
+			// The French street name is matched greedily up to the next
+			// comma, digit or line break; drop the trailing spaces
+			end = start + len(strings.TrimRight(content[start:end], " \t-"))
+
+			confidence := format.confidence
+			var tail []int
+			if format.tail != nil {
+				if tail = format.tail.FindStringIndex(content[end:]); tail != nil {
+					end += tail[1]
+					confidence = format.withTail
+				}
+			}
+			if format.bareNeedsKeyword && tail == nil &&
+				nearbyKeyword(content, start, end, addressKeywords) == "" {
+				continue
+			}
+
+			if overlaps(start, end) {
+				continue
+			}
+
+			candidates = append(candidates, Candidate{
+				Type:       TypeAddress,
+				Start:      start,
+				End:        end,
+				Confidence: confidence,
+				Keywords:   addressKeywords,
+				Signals:    []string{format.name},
+			})
+		}
+	}
+
+	return candidates
+}
@@ -0,0 +1,191 @@
+package scan
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"mime/quotedprintable"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// DefaultDecodeDepth is the number of nested encodings a new scanner
+// unwraps, as in base64 of URL-encoded text.
+const DefaultDecodeDepth = 2
+
+// decoder finds spans of one encoding and decodes them.
+type decoder struct {
+	name      string
+	marker    string // Text every span contains, if any, checked before the regex runs
+	regex     *regexp.Regexp
+	plausible func(content string, start, end int) bool // Whether a span is worth decoding; nil for all
+	decode    func(token string) (string, bool)
+}
+
+var decoders = []decoder{
+	{"base64", "", regexp.MustCompile(`[A-Za-z0-9+/_-]{16,}={0,2}`), plausibleBase64, decodeBase64},
+	{"hex", "", regexp.MustCompile(`\b(?:[0-9a-fA-F]{2}){8,}\b`), nil, decodeHex},
+	{"url", "%", regexp.MustCompile(`[A-Za-z0-9._~+-]*(?:%[0-9A-Fa-f]{2}[A-Za-z0-9._~+-]*)+`), nil, decodeURL},
+	{"quoted-printable", "=", regexp.MustCompile(`[^\s=]*(?:=(?:[0-9A-F]{2}|\r?\n)[^\s=]*)+`), nil, decodeQuotedPrintable},
+	{"unicode-escape", `\u`, regexp.MustCompile(`[^\s"'\\]*(?:\\u[0-9a-fA-F]{4}[^\s"'\\]*)+`), nil, decodeUnicodeEscapes},
+}
+
+// base64HintLookBehind is how far before a base64 span a hint such as
+// "base64," or "b64=" is looked for.
+const base64HintLookBehind = 32
+
+// base64Hints name the encoding just before a span of it.
+var base64Hints = []string{"base64", "b64"}
+
+// detectEncoded decodes encoded spans of content and detects PII in the
+// decoded text, unwrapping up to depth nested encodings. Candidates cover the
+// encoded span in content and record the encodings outermost first. Decoded
+// text is only scanned when it fires a trigger of the prefilter, so that
+// decoding an identifier into readable noise does not cost a full scan.
+func (s *Scanner) detectEncoded(content string, depth int) []Candidate {
+	candidates := make([]Candidate, 0)
+	if depth <= 0 {
+		return candidates
+	}
+
+	for _, d := range decoders {
+		if d.marker != "" && !strings.Contains(content, d.marker) {
+			continue
+		}
+		for _, loc := range d.regex.FindAllStringIndex(content, -1) {
+			start, end := loc[0], loc[1]
+			if d.plausible != nil && !d.plausible(content, start, end) {
+				continue
+			}
+			decoded, ok := d.decode(content[start:end])
+			if !ok {
+				continue
+			}
+
+			inner := make([]Candidate, 0)
+			if regions := s.prefilter.regions(decoded); s.prefilter.fired(regions) {
+				inner = s.detectRegions(decoded, regions)
+			}
+			inner = append(inner, s.detectEncoded(decoded, depth-1)...)
+			for _, candidate := range inner {
+				candidate.Start, candidate.End = start, end
+				candidate.Encoding = append([]string{d.name}, candidate.Encoding...)
+				candidate.Signals = append(candidate.Signals, "decoded from "+d.name)
+				candidates = append(candidates, candidate)
+			}
+		}
+	}
+
+	return candidates
+}
+
+// readable reports whether decoded bytes look like text rather than the
+// noise produced by decoding something that was not encoded.
+func readable(s string) bool {
+	if len(s) < 4 || !utf8.ValidString(s) {
+		return false
+	}
+	printable, total := 0, 0
+	for _, r := range s {
+		total++
+		if unicode.IsGraphic(r) || unicode.IsSpace(r) {
+			printable++
+		}
+	}
+	return printable*20 >= total*19
+}
+
+// plausibleBase64 reports whether the span content[start:end] is padded,
+// has a length that is a multiple of four, or follows a hint naming the
+// encoding. Identifiers of mixed letters and digits rarely do all three.
+func plausibleBase64(content string, start, end int) bool {
+	if strings.HasSuffix(content[start:end], "=") || (end-start)%4 == 0 {
+		return true
+	}
+	before := strings.ToLower(content[max(0, start-base64HintLookBehind):start])
+	for _, hint := range base64Hints {
+		if strings.Contains(before, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeBase64(token string) (string, bool) {
+	trimmed := strings.TrimRight(token, "=")
+	encoding := base64.RawStdEncoding
+	if strings.ContainsAny(trimmed, "-_") {
+		if strings.ContainsAny(trimmed, "+/") {
+			return "", false
+		}
+		encoding = base64.RawURLEncoding
+	}
+
+	data, err := encoding.DecodeString(trimmed)
+	if err != nil || !readable(string(data)) {
+		return "", false
+	}
+	return string(data), true
+}
+
+func decodeHex(token string) (string, bool) {
+	data, err := hex.DecodeString(token)
+	if err != nil || !readable(string(data)) {
+		return "", false
+	}
+	return string(data), true
+}
+
+func decodeURL(token string) (string, bool) {
+	decoded, err := url.QueryUnescape(token)
+	if err != nil || decoded == token || !readable(decoded) {
+		return "", false
+	}
+	return decoded, true
+}
+
+func decodeQuotedPrintable(token string) (string, bool) {
+	data, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(token)))
+	if err != nil || string(data) == token || !readable(string(data)) {
+		return "", false
+	}
+	return string(data), true
+}
+
+// decodeUnicodeEscapes decodes \uXXXX escapes, joining surrogate pairs.
+func decodeUnicodeEscapes(token string) (string, bool) {
+	var decoded strings.Builder
+	for i := 0; i < len(token); {
+		if !strings.HasPrefix(token[i:], `\u`) || i+6 > len(token) {
+			decoded.WriteByte(token[i])
+			i++
+			continue
+		}
+		r := parseEscape(token[i+2 : i+6])
+		i += 6
+		if utf16.IsSurrogate(r) && strings.HasPrefix(token[i:], `\u`) && i+6 <= len(token) {
+			r = utf16.DecodeRune(r, parseEscape(token[i+2:i+6]))
+			i += 6
+		}
+		decoded.WriteRune(r)
+	}
+
+	if !readable(decoded.String()) {
+		return "", false
+	}
+	return decoded.String(), true
+}
+
+// parseEscape parses the four hex digits of a \u escape.
+func parseEscape(digits string) rune {
+	n, err := strconv.ParseUint(digits, 16, 16)
+	if err != nil {
+		return utf8.RuneError
+	}
+	return rune(n)
+}
@@ -0,0 +1,144 @@
+package scan
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Candidate is a possible PII match reported by a Detector. The scanner
+// scores, filters and converts candidates into PIIRecords, so every detector
+// produces findings of the same shape.
+type Candidate struct {
+	Type       PIIType
+	Start      int        // Byte offset of the match in the scanned content
+	End        int        // Byte offset just past the match
+	Value      string     // Defaults to the matched content
+	Confidence float64    // Base confidence before context scoring; 0 uses the default
+	Keywords   []string   // Words that raise confidence when found nearby
+	Validation Validation // Outcome of any structural check; Reject drops the candidate
+	Signals    []string   // Detector-specific evidence recorded with the finding
+	RiskLevel  string     // Defaults to the risk level of Type
+	Redaction  string     // Defaults to the upper-cased type in brackets
+	Normalized string     // Canonical form of the value, if the detector has one
+	Country    string     // ISO 3166 region the value belongs to, if known
+	Encoding   []string   // Encodings unwrapped to find the value, outermost first
+}
+
+// Detector finds PII candidates in text. Detect must not modify shared state,
+// as a detector may be used by several scanners at once.
+type Detector interface {
+	Detect(content string) []Candidate
+}
+
+// DetectorFunc adapts a function to the Detector interface.
+type DetectorFunc func(content string) []Candidate
+
+// Detect calls f(content).
+func (f DetectorFunc) Detect(content string) []Candidate {
+	return f(content)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Detector)
+)
+
+// RegisterDetector makes a detector available under the given name. Every
+// scanner built afterwards runs it alongside the regex patterns. Packages
+// providing detectors typically call it from an init function, so importing
+// the package is enough to enable them. Registering a name twice replaces the
+// earlier detector.
+func RegisterDetector(name string, detector Detector) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry[name] = detector
+}
+
+// Detectors returns the names of all registered detectors in sorted order.
+func Detectors() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	return sortedKeys(registry)
+}
+
+// registeredDetectors returns a copy of the detector registry.
+func registeredDetectors() map[string]Detector {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	detectors := make(map[string]Detector, len(registry))
+	for name, detector := range registry {
+		detectors[name] = detector
+	}
+	return detectors
+}
+
+// Detect finds candidates for the pattern's regex, applying its validator.
+func (p *Pattern) Detect(content string) []Candidate {
+	candidates := make([]Candidate, 0)
+
+	for _, loc := range p.Regex.FindAllStringIndex(content, -1) {
+		candidate := Candidate{
+			Type:       p.PIIType,
+			Start:      loc[0],
+			End:        loc[1],
+			Value:      content[loc[0]:loc[1]],
+			Confidence: p.Confidence,
+			Keywords:   p.Keywords,
+			RiskLevel:  p.RiskLevel,
+			Redaction:  p.Replacement,
+		}
+		if p.Validate != nil {
+			candidate.Validation = p.Validate(candidate.Value)
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	return candidates
+}
+
+// detect runs every pattern and detector over content, in a fixed order, and
+// fills in candidate defaults.
+func (s *Scanner) detect(content string) []Candidate {
+	return s.detectRegions(content, s.prefilter.regions(content))
+}
+
+// detectRegions is detect with the prefilter regions of content already
+// found.
+func (s *Scanner) detectRegions(content string, stageRegions [][]region) []Candidate {
+	candidates := make([]Candidate, 0)
+
+	for i, regions := range stageRegions {
+		for _, r := range regions {
+			for _, candidate := range s.stages[i].Detect(content[r.start:r.end]) {
+				if candidate.Start < 0 || candidate.End > r.end-r.start || candidate.Start > candidate.End {
+					continue
+				}
+				candidate.Start += r.start
+				candidate.End += r.start
+				candidates = append(candidates, candidate)
+			}
+		}
+	}
+
+	for i := range candidates {
+		candidate := &candidates[i]
+		if candidate.Value == "" {
+			candidate.Value = content[candidate.Start:candidate.End]
+		}
+		if candidate.RiskLevel == "" {
+			candidate.RiskLevel = getRiskLevel(candidate.Type)
+		}
+		if candidate.Redaction == "" {
+			candidate.Redaction = "[" + strings.ToUpper(string(candidate.Type)) + "]"
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Start < candidates[j].Start
+	})
+	return candidates
+}
@@ -0,0 +1,235 @@
+package scan
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ibanLengths are the IBAN lengths of the countries that issue IBANs.
+var ibanLengths = map[string]int{
+	"AD": 24, "AE": 23, "AL": 28, "AT": 20, "AZ": 28, "BA": 20, "BE": 16, "BG": 22,
+	"BH": 22, "BR": 29, "BY": 28, "CH": 21, "CR": 22, "CY": 28, "CZ": 24, "DE": 22,
+	"DK": 18, "DO": 28, "EE": 20, "EG": 29, "ES": 24, "FI": 18, "FO": 18, "FR": 27,
+	"GB": 22, "GE": 22, "GI": 23, "GL": 18, "GR": 27, "GT": 28, "HR": 21, "HU": 28,
+	"IE": 22, "IL": 23, "IQ": 23, "IS": 26, "IT": 27, "JO": 30, "KW": 30, "KZ": 20,
+	"LB": 28, "LC": 32, "LI": 21, "LT": 20, "LU": 20, "LV": 21, "MC": 27, "MD": 24,
+	"ME": 22, "MK": 19, "MR": 27, "MT": 31, "MU": 30, "NL": 18, "NO": 15, "PK": 24,
+	"PL": 28, "PS": 29, "PT": 25, "QA": 29, "RO": 24, "RS": 22, "SA": 24, "SC": 31,
+	"SE": 24, "SI": 19, "SK": 24, "SM": 27, "ST": 25, "SV": 28, "TL": 23, "TN": 24,
+	"TR": 26, "UA": 29, "VA": 22, "VG": 24, "XK": 20,
+}
+
+// countryCodes are the ISO 3166-1 alpha-2 country codes, which form the
+// fifth and sixth characters of a BIC.
+var countryCodes = func() map[string]bool {
+	codes := make(map[string]bool)
+	for _, code := range strings.Fields(`
+		AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ BA BB BD BE BF BG BH BI BJ BL BM BN BO BQ BR BS
+		BT BV BW BY BZ CA CC CD CF CG CH CI CK CL CM CN CO CR CU CV CW CX CY CZ DE DJ DK DM DO DZ EC EE
+		EG EH ER ES ET FI FJ FK FM FO FR GA GB GD GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY HK HM
+		HN HR HT HU ID IE IL IM IN IO IQ IR IS IT JE JM JO JP KE KG KH KI KM KN KP KR KW KY KZ LA LB LC
+		LI LK LR LS LT LU LV LY MA MC MD ME MF MG MH MK ML MM MN MO MP MQ MR MS MT MU MV MW MX MY MZ NA
+		NC NE NF NG NI NL NO NP NR NU NZ OM PA PE PF PG PH PK PL PM PN PR PS PT PW PY QA RE RO RS RU RW
+		SA SB SC SD SE SG SH SI SJ SK SL SM SN SO SR SS ST SV SX SY SZ TC TD TF TG TH TJ TK TL TM TN TO
+		TR TT TV TW TZ UA UG UM US UY UZ VA VC VE VG VI VN VU WF WS XK YE YT ZA ZM ZW`) {
+		codes[code] = true
+	}
+	return codes
+}()
+
+var (
+	ibanRegex = regexp.MustCompile(`\b[A-Z]{2}[0-9]{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,4})?\b`)
+	bicRegex  = regexp.MustCompile(`\b[A-Z]{4}[A-Z]{2}[A-Z0-9]{2}(?:[A-Z0-9]{3})?\b`)
+	abaRegex  = regexp.MustCompile(`\b[0-9]{9}\b`)
+
+	// sortCodeAccountRegex matches a UK sort code followed by an 8-digit
+	// account number, optionally labelled.
+	sortCodeAccountRegex = regexp.MustCompile(`\b[0-9]{2}-[0-9]{2}-[0-9]{2}[ \t,;/]*(?:(?i:a/c|acc(?:ount)?(?:[ \t]*(?:no|number|num))?)\.?[ \t:#]*)?[0-9]{8}\b`)
+)
+
+var (
+	ibanKeywords     = []string{"iban", "account", "bank", "transfer", "sepa"}
+	bicKeywords      = []string{"swift", "bic", "swift code", "bank identifier"}
+	routingKeywords  = []string{"routing", "aba", "rtn", "transit", "routing number"}
+	sortCodeKeywords = []string{"sort code", "account", "bank", "a/c"}
+)
+
+func init() {
+	RegisterDetector("financial", Triggered(Trigger{Digits: 2, Literals: bicKeywords}, DetectorFunc(detectFinancial)))
+}
+
+// ValidateIBAN checks an IBAN's length for its country and its mod-97 check
+// digits.
+func ValidateIBAN(value string) Validation {
+	iban := strings.ToUpper(strings.ReplaceAll(value, " ", ""))
+	if len(iban) < 15 {
+		return rejected("too short for an IBAN")
+	}
+
+	country := iban[:2]
+	length, exists := ibanLengths[country]
+	if !exists {
+		return rejected(country + " does not issue IBANs")
+	}
+	if len(iban) != length {
+		return rejected(country + " IBANs have " + strconv.Itoa(length) + " characters, not " + strconv.Itoa(len(iban)))
+	}
+
+	// Move the country code and check digits to the end and read letters as
+	// 10-35; the result mod 97 must be 1
+	remainder := 0
+	for _, c := range iban[4:] + iban[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			remainder = (remainder*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			remainder = (remainder*100 + int(c-'A') + 10) % 97
+		default:
+			return rejected("invalid character in IBAN")
+		}
+	}
+	if remainder != 1 {
+		return rejected("mod-97 check failed")
+	}
+	return passed("mod-97 check passed")
+}
+
+// ValidateABA checks a US ABA routing number's prefix and checksum.
+func ValidateABA(value string) Validation {
+	digits := digitsOf(value)
+	if len(digits) != 9 {
+		return rejected("not 9 digits")
+	}
+
+	prefix, _ := strconv.Atoi(digits[:2])
+	if !(prefix <= 12 || (prefix >= 21 && prefix <= 32) || (prefix >= 61 && prefix <= 72) || prefix == 80) {
+		return rejected("prefix " + digits[:2] + " is not a Federal Reserve routing symbol")
+	}
+
+	weights := []int{3, 7, 1}
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(digits[i]-'0') * weights[i%3]
+	}
+	if sum%10 != 0 {
+		return rejected("checksum failed")
+	}
+	return passed("checksum passed")
+}
+
+// ValidateBIC checks that a SWIFT/BIC code names a real country.
+func ValidateBIC(value string) Validation {
+	if len(value) != 8 && len(value) != 11 {
+		return rejected("not 8 or 11 characters")
+	}
+	if !countryCodes[value[4:6]] {
+		return rejected(value[4:6] + " is not a country code")
+	}
+	// A zero as the second location character marks a test BIC
+	if value[7] == '0' {
+		return downgraded("test BIC", 0.3)
+	}
+	return passed("valid country code")
+}
+
+// trimIBAN shortens a match that ran on into following text to the length
+// of an IBAN for its country, when that length ends at a group boundary.
+func trimIBAN(content string, start, end int) int {
+	length, exists := ibanLengths[content[start:start+2]]
+	if !exists {
+		return end
+	}
+
+	n := 0
+	for i := start; i < end; i++ {
+		if content[i] != ' ' {
+			n++
+		}
+		if n == length {
+			if i+1 == end || content[i+1] == ' ' {
+				return i + 1
+			}
+			break
+		}
+	}
+	return end
+}
+
+// detectFinancial finds IBANs, SWIFT/BIC codes, US routing numbers and UK
+// sort code and account number pairs.
+func detectFinancial(content string) []Candidate {
+	candidates := make([]Candidate, 0)
+
+	for _, loc := range ibanRegex.FindAllStringIndex(content, -1) {
+		start, end := loc[0], trimIBAN(content, loc[0], loc[1])
+		iban := strings.ReplaceAll(content[start:end], " ", "")
+		candidates = append(candidates, Candidate{
+			Type:       TypeBankAccount,
+			Start:      start,
+			End:        end,
+			Confidence: 0.75,
+			Keywords:   ibanKeywords,
+			Validation: ValidateIBAN(iban),
+			Normalized: iban,
+			Country:    iban[:2],
+			Signals:    []string{"IBAN format"},
+		})
+	}
+
+	// BICs look like any upper-case word, so require a keyword
+	for _, loc := range bicRegex.FindAllStringIndex(content, -1) {
+		start, end := loc[0], loc[1]
+		if nearbyKeyword(content, start, end, bicKeywords) == "" {
+			continue
+		}
+		bic := content[start:end]
+		candidates = append(candidates, Candidate{
+			Type:       TypeFinancialInfo,
+			Start:      start,
+			End:        end,
+			Confidence: 0.6,
+			Keywords:   bicKeywords,
+			Validation: ValidateBIC(bic),
+			Country:    bic[4:6],
+			Signals:    []string{"SWIFT/BIC format"},
+		})
+	}
+
+	for _, loc := range abaRegex.FindAllStringIndex(content, -1) {
+		start, end := loc[0], loc[1]
+		if !standalone(content, start, end) || nearbyKeyword(content, start, end, routingKeywords) == "" {
+			continue
+		}
+		candidates = append(candidates, Candidate{
+			Type:       TypeFinancialInfo,
+			Start:      start,
+			End:        end,
+			Confidence: 0.6,
+			Keywords:   routingKeywords,
+			Validation: ValidateABA(content[start:end]),
+			Country:    "US",
+			Signals:    []string{"ABA routing number format"},
+		})
+	}
+
+	for _, loc := range sortCodeAccountRegex.FindAllStringIndex(content, -1) {
+		start, end := loc[0], loc[1]
+		if !standalone(content, start, end) {
+			continue
+		}
+		digits := digitsOf(content[start:end])
+		candidates = append(candidates, Candidate{
+			Type:       TypeBankAccount,
+			Start:      start,
+			End:        end,
+			Confidence: 0.7,
+			Keywords:   sortCodeKeywords,
+			Normalized: digits[:6] + " " + digits[6:],
+			Country:    "GB",
+			Signals:    []string{"UK sort code and account number"},
+		})
+	}
+
+	return candidates
+}
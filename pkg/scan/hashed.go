@@ -0,0 +1,131 @@
+package scan
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+// TypePseudonymized is a hash of PII. Unsalted hashes of emails, phone
+// numbers and similar values can be reversed by hashing candidate values, so
+// they remain personal data under GDPR.
+const TypePseudonymized PIIType = "pseudonymized"
+
+var (
+	digestRegex = regexp.MustCompile(`\b(?:[0-9a-fA-F]{64}|[0-9a-fA-F]{40}|[0-9a-fA-F]{32})\b`)
+
+	// hashFieldRegex matches names of fields holding a hash of PII, such as
+	// email_sha256, hashed_phone or md5(email), capturing the kind of PII.
+	hashFieldRegex = regexp.MustCompile(`(?i)(?:\b(e-?mail|phone|mobile|msisdn|ssn|name|ip|dob|user[_ -]?id)[_ -]?(?:md5|sha-?1|sha-?256|hash(?:ed)?|digest)|\b(?:md5|sha-?1|sha-?256|hash(?:ed)?)[_ -(]+(e-?mail|phone|mobile|msisdn|ssn|name|ip|dob|user[_ -]?id)\)?)["']?\s*[:=]\s*["']?$`)
+)
+
+var hashKeywords = []string{"hash", "hashed", "md5", "sha1", "sha256", "digest", "pseudonymized", "anonymized"}
+
+// digestAlgorithms names hash algorithms by hex digest length.
+var digestAlgorithms = map[int]string{32: "MD5", 40: "SHA-1", 64: "SHA-256"}
+
+// knownDigest describes a known value whose digest was precomputed.
+type knownDigest struct {
+	algorithm string
+	kind      string
+}
+
+// hashKnownValues returns the digests of values keyed by hex, for looking up
+// digests found in content.
+func hashKnownValues(values []string) map[string]knownDigest {
+	digests := make(map[string]knownDigest)
+
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		kind := valueKind(value)
+		for _, variant := range hashVariants(value, kind) {
+			md5Sum := md5.Sum([]byte(variant))
+			sha1Sum := sha1.Sum([]byte(variant))
+			sha256Sum := sha256.Sum256([]byte(variant))
+			digests[hex.EncodeToString(md5Sum[:])] = knownDigest{"MD5", kind}
+			digests[hex.EncodeToString(sha1Sum[:])] = knownDigest{"SHA-1", kind}
+			digests[hex.EncodeToString(sha256Sum[:])] = knownDigest{"SHA-256", kind}
+		}
+	}
+
+	return digests
+}
+
+// valueKind guesses what kind of PII a known value is.
+func valueKind(value string) string {
+	switch {
+	case strings.Contains(value, "@"):
+		return "email"
+	case ValidateSSN(value).Status == ValidationPassed && strings.Count(value, "-") == 2:
+		return "SSN"
+	case ValidatePhone(value).Status == ValidationPassed:
+		return "phone number"
+	}
+	return "value"
+}
+
+// hashVariants returns the normalizations of a value that systems commonly
+// hash: as written, trimmed and lower-cased for emails, and digits-only or
+// E.164 for phone numbers and SSNs.
+func hashVariants(value, kind string) []string {
+	variants := []string{value}
+	switch kind {
+	case "email":
+		variants = append(variants, strings.ToLower(value))
+	case "phone number":
+		normalized, _, _ := parsePhone(value)
+		variants = append(variants, digitsOf(value), normalized, strings.TrimPrefix(normalized, "+"))
+	case "SSN":
+		variants = append(variants, digitsOf(value))
+	}
+	return variants
+}
+
+// detectHashes finds digests of PII in content.
+func (s *Scanner) detectHashes(content string) []Candidate {
+	candidates := make([]Candidate, 0)
+
+	for _, loc := range digestRegex.FindAllStringIndex(content, -1) {
+		start, end := loc[0], loc[1]
+		digest := strings.ToLower(content[start:end])
+		algorithm := digestAlgorithms[len(digest)]
+
+		if known, exists := s.knownDigests[digest]; exists {
+			candidates = append(candidates, Candidate{
+				Type:       TypePseudonymized,
+				Start:      start,
+				End:        end,
+				Confidence: 0.95,
+				Keywords:   hashKeywords,
+				Normalized: digest,
+				Validation: passed("unsalted " + known.algorithm + " of a known " + known.kind),
+				Signals:    []string{known.algorithm + " digest"},
+			})
+			continue
+		}
+
+		lineStart := strings.LastIndexByte(content[:start], '\n') + 1
+		field := hashFieldRegex.FindStringSubmatch(content[max(lineStart, start-64):start])
+		if field == nil {
+			continue
+		}
+		kind := field[1] + field[2]
+		candidates = append(candidates, Candidate{
+			Type:       TypePseudonymized,
+			Start:      start,
+			End:        end,
+			Confidence: 0.6,
+			Keywords:   hashKeywords,
+			Normalized: digest,
+			Signals:    []string{algorithm + " digest", "field names a hash of " + strings.ToLower(kind)},
+		})
+	}
+
+	return candidates
+}
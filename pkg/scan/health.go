@@ -0,0 +1,294 @@
+package scan
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Health data types. Together with TypeMedicalRecord and TypeBiometric they
+// are protected health information under HIPAA.
+const (
+	TypeDiagnosisCode PIIType = "diagnosis_code"
+	TypeProcedureCode PIIType = "procedure_code"
+	TypeHealthPlanID  PIIType = "health_plan_id"
+	TypeNPI           PIIType = "npi"
+	TypeDEA           PIIType = "dea_number"
+)
+
+var (
+	icd10Regex = regexp.MustCompile(`\b[A-TV-Z][0-9][0-9AB](?:\.[0-9A-TV-Z]{1,4})?\b`)
+	cptRegex   = regexp.MustCompile(`\b(?:[0-9]{4}[0-9FTU]|[A-V][0-9]{4})\b`)
+	npiRegex   = regexp.MustCompile(`\b[12][0-9]{9}\b`)
+	deaRegex   = regexp.MustCompile(`\b[ABCDEFGHJKLMPRSTUX][A-Z9][0-9]{7}\b`)
+
+	// mbiRegex matches a Medicare Beneficiary Identifier, which leaves out
+	// the letters S, L, O, I, B and Z.
+	mbiRegex = regexp.MustCompile(`\b[1-9][AC-HJKMNP-RT-Y][AC-HJKMNP-RT-Y0-9][0-9]-?[AC-HJKMNP-RT-Y][AC-HJKMNP-RT-Y0-9][0-9]-?[AC-HJKMNP-RT-Y]{2}[0-9]{2}\b`)
+
+	// memberIDRegex matches a health plan member number introduced by a
+	// label.
+	memberIDRegex = regexp.MustCompile(`(?i:member|subscriber|beneficiary|medicaid|medicare|insurance|policy|health[ \t_]?plan)[ \t_]*(?i:id|number|no|num|#)\.?"?[ \t]*[:#=]?[ \t]*"?([A-Z0-9][A-Z0-9-]{4,18}[A-Z0-9])\b`)
+
+	// biometricFieldRegex matches field names that hold biometric templates,
+	// with the separator that introduces their value. A bare "fingerprint"
+	// is as likely to name a certificate or key digest, and needs context.
+	biometricFieldRegex = regexp.MustCompile(`(?i)\b(finger[_ -]?prints?(?:[_ -]?(?:template|data|minutiae))?|face[_ -]?(?:template|encoding|embedding|print|descriptor|vector)s?|faceprint|iris[_ -]?(?:code|template|scan)|voice[_ -]?(?:print|template)|palm[_ -]?(?:print|vein)|retina[_ -]?(?:scan|template)|minutiae|biometrics?(?:[_ -]?(?:template|data|hash))?)["']?\s*[:=]\s*`)
+
+	// biometricNameRegex matches names specific to biometric templates, which
+	// must be near a base64 blob that is not the value of a biometric field.
+	// Weaker words such as "face" or "template" are common in manifests and
+	// certificate bundles.
+	biometricNameRegex = regexp.MustCompile(`(?i)\b(?:finger[_ -]?prints?[_ -]?(?:templates?|data|minutiae)|face[_ -]?(?:templates?|encodings?|embeddings?|descriptors?|vectors?)|faceprints?|iris[_ -]?(?:codes?|templates?)|voice[_ -]?(?:prints?|templates?)|palm[_ -]?(?:prints?|veins?)|retina[_ -]?(?:scans?|templates?)|minutiae|biometrics?[_ -]?(?:templates?|data))\b`)
+
+	base64BlobRegex = regexp.MustCompile(`[A-Za-z0-9+/]{128,}={0,2}`)
+	hexBlobRegex    = regexp.MustCompile(`^["']?[0-9a-fA-F]{64,}`)
+	base64Regex     = regexp.MustCompile(`^["']?[A-Za-z0-9+/]{64,}={0,2}`)
+	vectorRegex     = regexp.MustCompile(`^\[\s*-?[0-9.]+(?:e-?[0-9]+)?(?:\s*,\s*-?[0-9.]+(?:e-?[0-9]+)?){15,}\s*\]`)
+)
+
+var (
+	diagnosisKeywords  = []string{"icd", "icd-10", "icd10", "diagnosis", "dx", "diagnosed", "condition"}
+	procedureKeywords  = []string{"cpt", "hcpcs", "procedure code", "procedure", "billing code"}
+	npiKeywords        = []string{"npi", "national provider", "provider id", "prescriber"}
+	deaKeywords        = []string{"dea", "prescriber", "controlled substance", "dea number"}
+	healthPlanKeywords = []string{"medicare", "medicaid", "member", "beneficiary", "insurance", "health plan", "mbi"}
+	biometricKeywords  = []string{"fingerprint", "biometric", "face", "facial", "iris", "retina", "voiceprint", "template", "minutiae"}
+
+	// biometricContextKeywords confirm that a bare "fingerprint" field is
+	// biometric rather than the digest of a certificate or key
+	biometricContextKeywords = []string{"biometric", "biometrics", "minutiae", "facial", "iris", "retina", "voiceprint", "palm", "enrollment", "enrolment"}
+
+	// biometricTriggers start every biometric field name and keyword
+	biometricTriggers = []string{"finger", "face", "facial", "iris", "voice", "palm", "retina", "minutiae", "biometric", "template"}
+)
+
+func init() {
+	RegisterDetector("health", Triggered(Trigger{Digits: 2}, DetectorFunc(detectHealth)))
+	RegisterDetector("biometrics", Triggered(Trigger{Literals: biometricTriggers}, DetectorFunc(detectBiometrics)))
+}
+
+// isHealthData reports whether piiType is protected health information.
+func isHealthData(piiType PIIType) bool {
+	switch piiType {
+	case TypeMedicalRecord, TypeDiagnosisCode, TypeProcedureCode, TypeHealthPlanID, TypeBiometric:
+		return true
+	}
+	return false
+}
+
+// ValidateNPI checks a US National Provider Identifier, whose check digit is
+// the Luhn digit of the number prefixed with 80840.
+func ValidateNPI(value string) Validation {
+	digits := digitsOf(value)
+	if len(digits) != 10 {
+		return rejected("not 10 digits")
+	}
+	if !luhnValid("80840" + digits) {
+		return rejected("NPI check digit failed")
+	}
+	return passed("NPI check digit passed")
+}
+
+// ValidateDEA checks a DEA registration number: the sum of the first, third
+// and fifth digits plus twice the sum of the second, fourth and sixth ends
+// in the seventh digit.
+func ValidateDEA(value string) Validation {
+	value = strings.ToUpper(value)
+	if len(value) != 9 {
+		return rejected("not 9 characters")
+	}
+	digits := value[2:]
+	if digitsOf(digits) != digits {
+		return rejected("not 7 digits after the prefix")
+	}
+
+	sum := int(digits[0]-'0') + int(digits[2]-'0') + int(digits[4]-'0') +
+		2*(int(digits[1]-'0')+int(digits[3]-'0')+int(digits[5]-'0'))
+	if sum%10 != int(digits[6]-'0') {
+		return rejected("DEA check digit failed")
+	}
+	return passed("DEA check digit passed")
+}
+
+// detectHealth finds diagnosis and procedure codes, provider identifiers and
+// health plan member numbers.
+func detectHealth(content string) []Candidate {
+	candidates := make([]Candidate, 0)
+
+	// Codes are short and look like many other identifiers, so all but the
+	// Medicare identifier need a keyword nearby
+	labelled := []struct {
+		regex      *regexp.Regexp
+		piiType    PIIType
+		keywords   []string
+		validate   Validator
+		confidence float64
+		country    string
+		signal     string
+	}{
+		{icd10Regex, TypeDiagnosisCode, diagnosisKeywords, nil, 0.55, "", "ICD-10 code format"},
+		{cptRegex, TypeProcedureCode, procedureKeywords, nil, 0.5, "US", "CPT/HCPCS code format"},
+		{npiRegex, TypeNPI, npiKeywords, ValidateNPI, 0.55, "US", "NPI format"},
+		{deaRegex, TypeDEA, deaKeywords, ValidateDEA, 0.55, "US", "DEA number format"},
+	}
+	for _, format := range labelled {
+		for _, loc := range format.regex.FindAllStringIndex(content, -1) {
+			start, end := loc[0], loc[1]
+			if !standalone(content, start, end) || nearbyKeyword(content, start, end, format.keywords) == "" {
+				continue
+			}
+			candidate := Candidate{
+				Type:       format.piiType,
+				Start:      start,
+				End:        end,
+				Confidence: format.confidence,
+				Keywords:   format.keywords,
+				Country:    format.country,
+				Signals:    []string{format.signal},
+			}
+			if format.validate != nil {
+				candidate.Validation = format.validate(content[start:end])
+			}
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	overlaps := func(start, end int) bool {
+		for _, c := range candidates {
+			if start < c.End && c.Start < end {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, loc := range mbiRegex.FindAllStringIndex(content, -1) {
+		candidates = append(candidates, Candidate{
+			Type:       TypeHealthPlanID,
+			Start:      loc[0],
+			End:        loc[1],
+			Confidence: 0.65,
+			Keywords:   healthPlanKeywords,
+			Normalized: strings.ReplaceAll(content[loc[0]:loc[1]], "-", ""),
+			Country:    "US",
+			Signals:    []string{"Medicare Beneficiary Identifier format"},
+		})
+	}
+
+	for _, loc := range memberIDRegex.FindAllStringSubmatchIndex(content, -1) {
+		start, end := loc[2], loc[3]
+		if len(digitsOf(content[start:end])) < 3 || overlaps(start, end) {
+			continue
+		}
+		candidates = append(candidates, Candidate{
+			Type:       TypeHealthPlanID,
+			Start:      start,
+			End:        end,
+			Confidence: 0.6,
+			Keywords:   healthPlanKeywords,
+			Signals:    []string{"labelled as a health plan member number"},
+		})
+	}
+
+	return candidates
+}
+
+// isBareFingerprint reports whether a biometric field name is just
+// "fingerprint", with no qualifier such as "template".
+func isBareFingerprint(field string) bool {
+	name := strings.ToLower(strings.NewReplacer("_", "", "-", "", " ", "").Replace(field))
+	return name == "fingerprint" || name == "fingerprints"
+}
+
+// isDigestLength reports whether hex digits are as long as an MD5, SHA-1 or
+// SHA-256 digest, which is what fingerprints of certificates and keys are.
+func isDigestLength(hex string) bool {
+	switch len(hex) {
+	case 32, 40, 64:
+		return true
+	}
+	return false
+}
+
+// nearbyBiometricName reports whether a name specific to biometric templates
+// appears around content[start:end].
+func nearbyBiometricName(content string, start, end int) bool {
+	window := content[max(0, start-keywordLookBehind):start] + " " +
+		content[end:min(len(content), end+keywordLookAhead)]
+	return biometricNameRegex.MatchString(window)
+}
+
+// detectBiometrics finds biometric templates: encoded values of fingerprint,
+// face, iris and voice fields, and large base64 blobs next to the name of a
+// biometric template.
+func detectBiometrics(content string) []Candidate {
+	candidates := make([]Candidate, 0)
+
+	for _, loc := range biometricFieldRegex.FindAllStringSubmatchIndex(content, -1) {
+		value := content[loc[1]:]
+
+		var match []int
+		encoding := ""
+		for _, blob := range []struct {
+			regex *regexp.Regexp
+			name  string
+		}{
+			{vectorRegex, "feature vector"},
+			{hexBlobRegex, "hex data"},
+			{base64Regex, "base64 data"},
+		} {
+			if match = blob.regex.FindStringIndex(value); match != nil {
+				encoding = blob.name
+				break
+			}
+		}
+		if match == nil {
+			continue
+		}
+		field := content[loc[2]:loc[3]]
+		if isBareFingerprint(field) && nearbyKeyword(content, loc[0], loc[1]+match[1], biometricContextKeywords) == "" {
+			continue
+		}
+		if encoding == "hex data" && isDigestLength(strings.Trim(value[:match[1]], `"'`)) {
+			continue
+		}
+
+		start, end := loc[1], loc[1]+match[1]
+		if content[start] == '"' || content[start] == '\'' {
+			start++
+		}
+		candidates = append(candidates, Candidate{
+			Type:       TypeBiometric,
+			Start:      start,
+			End:        end,
+			Confidence: 0.85,
+			Keywords:   biometricKeywords,
+			Signals:    []string{encoding + " in field \"" + field + "\""},
+		})
+	}
+
+	fields := len(candidates)
+	for _, loc := range base64BlobRegex.FindAllStringIndex(content, -1) {
+		start, end := loc[0], loc[1]
+		covered := false
+		for _, c := range candidates[:fields] {
+			if start < c.End && c.Start < end {
+				covered = true
+				break
+			}
+		}
+		if covered || !nearbyBiometricName(content, start, end) {
+			continue
+		}
+		candidates = append(candidates, Candidate{
+			Type:       TypeBiometric,
+			Start:      start,
+			End:        end,
+			Confidence: 0.55,
+			Keywords:   biometricKeywords,
+			Signals:    []string{"base64 data next to a biometric template name"},
+		})
+	}
+
+	return candidates
+}
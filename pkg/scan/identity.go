@@ -0,0 +1,389 @@
+package scan
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Identity document and tax identifier types.
+const (
+	TypePassport       PIIType = "passport"
+	TypeDriversLicense PIIType = "drivers_license"
+	TypeEIN            PIIType = "ein"
+	TypeITIN           PIIType = "itin"
+)
+
+var (
+	// mrzRegex matches the two 44-character lines of a passport's machine
+	// readable zone (ICAO 9303 TD3).
+	mrzRegex = regexp.MustCompile(`P[A-Z<][A-Z<]{3}[A-Z<]{39}\r?\n[A-Z0-9<]{9}[0-9][A-Z<]{3}[0-9]{6}[0-9][MFX<][0-9]{6}[0-9][A-Z0-9<]{14}[0-9<][0-9]`)
+
+	// passportRegex matches a passport number introduced by a label.
+	passportRegex = regexp.MustCompile(`(?i:passport)(?:[ \t_]*(?i:no|number|num|nr|#))?\.?"?[ \t]*[:#=]?[ \t]*"?([A-Z]{0,2}[0-9]{6,9})\b`)
+
+	// licenseRegex matches a driver's license number introduced by a label,
+	// with an optional state in parentheses.
+	licenseRegex = regexp.MustCompile(`(?i:driver'?s?[ \t_]*licen[cs]e|driving[ \t_]*licen[cs]e|\bDL)(?:[ \t_]*(?i:no|number|num|#))?\.?[ \t]*(?:\(([A-Z]{2})\))?"?[ \t]*[:#=]?[ \t]*"?([A-Z0-9*][A-Z0-9*-]{3,18}[A-Z0-9])\b`)
+
+	stateRegex = regexp.MustCompile(`\b` + usStates + `\b`)
+
+	einRegex  = regexp.MustCompile(`\b[0-9]{2}-?[0-9]{7}\b`)
+	itinRegex = regexp.MustCompile(`\b9[0-9]{2}-?[0-9]{2}-?[0-9]{4}\b`)
+)
+
+var (
+	passportKeywords = []string{"passport", "travel document", "nationality"}
+	licenseKeywords  = []string{"driver", "license", "licence", "dl", "dmv"}
+	einKeywords      = []string{"ein", "fein", "employer identification", "tax id", "taxpayer"}
+	itinKeywords     = []string{"itin", "taxpayer identification", "tax id", "irs"}
+)
+
+// licenseFormats are the driver's license number formats of each US state.
+var licenseFormats = func() map[string]*regexp.Regexp {
+	formats := map[string]string{
+		"AK": `[0-9]{1,7}`,
+		"AL": `[0-9]{1,8}`,
+		"AR": `[0-9]{4,9}`,
+		"AZ": `[A-Z][0-9]{8}|[0-9]{9}`,
+		"CA": `[A-Z][0-9]{7}`,
+		"CO": `[0-9]{9}|[A-Z][0-9]{3,6}|[A-Z]{2}[0-9]{2,5}`,
+		"CT": `[0-9]{9}`,
+		"DC": `[0-9]{7}|[0-9]{9}`,
+		"DE": `[0-9]{1,7}`,
+		"FL": `[A-Z][0-9]{12}`,
+		"GA": `[0-9]{7,9}`,
+		"HI": `[A-Z][0-9]{8}|[0-9]{9}`,
+		"IA": `[0-9]{9}|[0-9]{3}[A-Z]{2}[0-9]{4}`,
+		"ID": `[A-Z]{2}[0-9]{6}[A-Z]|[0-9]{9}`,
+		"IL": `[A-Z][0-9]{11,12}`,
+		"IN": `[A-Z][0-9]{9}|[0-9]{9,10}`,
+		"KS": `[A-Z][0-9][A-Z][0-9][A-Z]|[A-Z][0-9]{8}|[0-9]{9}`,
+		"KY": `[A-Z][0-9]{8,9}|[0-9]{9}`,
+		"LA": `[0-9]{1,9}`,
+		"MA": `[A-Z][0-9]{8}|[0-9]{9}`,
+		"MD": `[A-Z][0-9]{12}`,
+		"ME": `[0-9]{7,8}|[0-9]{7}[A-Z]`,
+		"MI": `[A-Z][0-9]{10}|[A-Z][0-9]{12}`,
+		"MN": `[A-Z][0-9]{12}`,
+		"MO": `[A-Z][0-9]{5,9}|[A-Z][0-9]{6}R|[0-9]{8}[A-Z]{2}|[0-9]{9}[A-Z]?`,
+		"MS": `[0-9]{9}`,
+		"MT": `[A-Z][0-9]{8}|[0-9]{9}|[0-9]{13,14}`,
+		"NC": `[0-9]{1,12}`,
+		"ND": `[A-Z]{3}[0-9]{6}|[0-9]{9}`,
+		"NE": `[A-Z][0-9]{6,8}`,
+		"NH": `[0-9]{2}[A-Z]{3}[0-9]{5}`,
+		"NJ": `[A-Z][0-9]{14}`,
+		"NM": `[0-9]{8,9}`,
+		"NV": `[0-9]{9,10}|[0-9]{12}|X[0-9]{8}`,
+		"NY": `[A-Z][0-9]{7}|[A-Z][0-9]{18}|[0-9]{8,9}|[0-9]{16}|[A-Z]{8}`,
+		"OH": `[A-Z][0-9]{4,8}|[A-Z]{2}[0-9]{3,7}|[0-9]{8}`,
+		"OK": `[A-Z][0-9]{9}|[0-9]{9}`,
+		"OR": `[0-9]{1,9}|[A-Z][0-9]{6}|[A-Z]{2}[0-9]{5}`,
+		"PA": `[0-9]{8}`,
+		"RI": `[0-9]{7}|[A-Z][0-9]{6}`,
+		"SC": `[0-9]{5,11}`,
+		"SD": `[0-9]{6,10}|[0-9]{12}`,
+		"TN": `[0-9]{7,9}`,
+		"TX": `[0-9]{7,8}`,
+		"UT": `[0-9]{4,10}`,
+		"VA": `[A-Z][0-9]{8,11}|[0-9]{9}`,
+		"VT": `[0-9]{8}|[0-9]{7}A`,
+		"WA": `WDL[A-Z0-9]{9}|[A-Z*]{1,7}[A-Z0-9*]{5}`,
+		"WI": `[A-Z][0-9]{13}`,
+		"WV": `[0-9]{7}|[A-Z]{1,2}[0-9]{5,6}`,
+		"WY": `[0-9]{9,10}`,
+	}
+
+	compiled := make(map[string]*regexp.Regexp, len(formats))
+	for state, format := range formats {
+		compiled[state] = regexp.MustCompile(`^(?:` + format + `)$`)
+	}
+	return compiled
+}()
+
+// stateNames maps US state names to their postal abbreviations.
+var stateNames = map[string]string{
+	"alabama": "AL", "alaska": "AK", "arizona": "AZ", "arkansas": "AR", "california": "CA",
+	"colorado": "CO", "connecticut": "CT", "delaware": "DE", "florida": "FL", "georgia": "GA",
+	"hawaii": "HI", "idaho": "ID", "illinois": "IL", "indiana": "IN", "iowa": "IA",
+	"kansas": "KS", "kentucky": "KY", "louisiana": "LA", "maine": "ME", "maryland": "MD",
+	"massachusetts": "MA", "michigan": "MI", "minnesota": "MN", "mississippi": "MS", "missouri": "MO",
+	"montana": "MT", "nebraska": "NE", "nevada": "NV", "new hampshire": "NH", "new jersey": "NJ",
+	"new mexico": "NM", "new york": "NY", "north carolina": "NC", "north dakota": "ND", "ohio": "OH",
+	"oklahoma": "OK", "oregon": "OR", "pennsylvania": "PA", "rhode island": "RI", "south carolina": "SC",
+	"south dakota": "SD", "tennessee": "TN", "texas": "TX", "utah": "UT", "vermont": "VT",
+	"virginia": "VA", "washington": "WA", "west virginia": "WV", "wisconsin": "WI", "wyoming": "WY",
+	"district of columbia": "DC",
+}
+
+// mrzCountries maps the ICAO codes of common passport issuers to ISO 3166
+// alpha-2 codes.
+var mrzCountries = map[string]string{
+	"USA": "US", "CAN": "CA", "MEX": "MX", "BRA": "BR", "ARG": "AR", "GBR": "GB", "IRL": "IE",
+	"FRA": "FR", "D<<": "DE", "ESP": "ES", "PRT": "PT", "ITA": "IT", "NLD": "NL", "BEL": "BE",
+	"LUX": "LU", "CHE": "CH", "AUT": "AT", "POL": "PL", "CZE": "CZ", "SWE": "SE", "NOR": "NO",
+	"DNK": "DK", "FIN": "FI", "GRC": "GR", "TUR": "TR", "RUS": "RU", "UKR": "UA", "ISR": "IL",
+	"IND": "IN", "PAK": "PK", "CHN": "CN", "JPN": "JP", "KOR": "KR", "SGP": "SG", "AUS": "AU",
+	"NZL": "NZ", "ZAF": "ZA", "NGA": "NG", "EGY": "EG", "ARE": "AE", "SAU": "SA", "PHL": "PH",
+	"IDN": "ID", "VNM": "VN", "THA": "TH", "MYS": "MY",
+}
+
+func init() {
+	RegisterDetector("identity", Triggered(Trigger{Digits: 2, Literals: []string{"<<", "passport", "driver", "driving", "licen", "dl"}}, DetectorFunc(detectIdentity)))
+}
+
+// mrzCheckDigit computes an ICAO 9303 check digit: characters are weighted
+// 7, 3, 1 in turn, with digits as themselves, letters as 10-35 and fillers
+// as zero.
+func mrzCheckDigit(s string) int {
+	weights := []int{7, 3, 1}
+	sum := 0
+	for i := 0; i < len(s); i++ {
+		value := 0
+		switch c := s[i]; {
+		case c >= '0' && c <= '9':
+			value = int(c - '0')
+		case c >= 'A' && c <= 'Z':
+			value = int(c-'A') + 10
+		}
+		sum += value * weights[i%3]
+	}
+	return sum % 10
+}
+
+// ValidateMRZ checks the check digits of a passport machine readable zone.
+func ValidateMRZ(value string) Validation {
+	lines := strings.Fields(value)
+	if len(lines) != 2 || len(lines[0]) != 44 || len(lines[1]) != 44 {
+		return rejected("not two 44-character MRZ lines")
+	}
+	line := lines[1]
+
+	fields := []struct {
+		name         string
+		start, end   int
+		checkDigitAt int
+	}{
+		{"document number", 0, 9, 9},
+		{"date of birth", 13, 19, 19},
+		{"expiry date", 21, 27, 27},
+	}
+	for _, field := range fields {
+		if mrzCheckDigit(line[field.start:field.end]) != int(line[field.checkDigitAt]-'0') {
+			return rejected(field.name + " check digit failed")
+		}
+	}
+	if line[42] != '<' && mrzCheckDigit(line[28:42]) != int(line[42]-'0') {
+		return rejected("personal number check digit failed")
+	}
+
+	composite := line[0:10] + line[13:20] + line[21:43]
+	if mrzCheckDigit(composite) != int(line[43]-'0') {
+		return rejected("composite check digit failed")
+	}
+	return passed("MRZ check digits passed")
+}
+
+// ValidateEIN checks that a US Employer Identification Number starts with a
+// prefix the IRS assigns.
+func ValidateEIN(value string) Validation {
+	digits := digitsOf(value)
+	if len(digits) != 9 {
+		return rejected("not 9 digits")
+	}
+
+	prefix, _ := strconv.Atoi(digits[:2])
+	switch {
+	case prefix >= 1 && prefix <= 6, prefix >= 10 && prefix <= 16, prefix >= 20 && prefix <= 27,
+		prefix >= 30 && prefix <= 48, prefix >= 50 && prefix <= 68, prefix >= 71 && prefix <= 77,
+		prefix >= 80 && prefix <= 88, prefix >= 90 && prefix <= 95, prefix >= 98:
+		return passed("assigned EIN prefix")
+	}
+	return rejected("prefix " + digits[:2] + " is not assigned")
+}
+
+// ValidateITIN checks the group digits of a US Individual Taxpayer
+// Identification Number.
+func ValidateITIN(value string) Validation {
+	digits := digitsOf(value)
+	if len(digits) != 9 || digits[0] != '9' {
+		return rejected("not 9 digits starting with 9")
+	}
+
+	group, _ := strconv.Atoi(digits[3:5])
+	switch {
+	case group >= 50 && group <= 65, group >= 70 && group <= 88, group >= 90 && group <= 92, group >= 94:
+		return passed("valid ITIN group")
+	}
+	return rejected("group " + digits[3:5] + " is not used in ITINs")
+}
+
+// licenseState finds the US state a driver's license number belongs to from
+// the text around it.
+func licenseState(content string, start, end int) string {
+	lineStart := strings.LastIndexByte(content[:start], '\n') + 1
+	lineEnd := len(content)
+	if i := strings.IndexByte(content[end:], '\n'); i >= 0 {
+		lineEnd = end + i
+	}
+	before := content[max(lineStart, start-48):start]
+	after := content[end:min(lineEnd, end+32)]
+
+	for _, window := range []string{before, after} {
+		if state := stateRegex.FindString(window); state != "" {
+			return state
+		}
+		lower := strings.ToLower(window)
+		for _, name := range sortedKeys(stateNames) {
+			if containsWord(lower, name) {
+				return stateNames[name]
+			}
+		}
+	}
+	return ""
+}
+
+// matchingStates returns the states whose license format matches number.
+func matchingStates(number string) []string {
+	states := make([]string, 0)
+	for _, state := range sortedKeys(licenseFormats) {
+		if licenseFormats[state].MatchString(number) {
+			states = append(states, state)
+		}
+	}
+	return states
+}
+
+// detectIdentity finds passports, US driver's licenses and US tax
+// identification numbers.
+func detectIdentity(content string) []Candidate {
+	candidates := make([]Candidate, 0)
+
+	for _, loc := range mrzRegex.FindAllStringIndex(content, -1) {
+		start, end := loc[0], loc[1]
+		mrz := content[start:end]
+		issuer := mrz[2:5]
+		candidates = append(candidates, Candidate{
+			Type:       TypePassport,
+			Start:      start,
+			End:        end,
+			Confidence: 0.8,
+			Keywords:   passportKeywords,
+			Validation: ValidateMRZ(mrz),
+			Normalized: strings.TrimRight(mrz[strings.IndexByte(mrz, '\n')+1:][:9], "<"),
+			Country:    mrzCountries[issuer],
+			Signals:    []string{"passport MRZ issued by " + strings.TrimRight(issuer, "<")},
+		})
+	}
+
+	mrzOverlaps := func(start, end int) bool {
+		for _, c := range candidates {
+			if start < c.End && c.Start < end {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, loc := range passportRegex.FindAllStringSubmatchIndex(content, -1) {
+		start, end := loc[2], loc[3]
+		if mrzOverlaps(start, end) {
+			continue
+		}
+		candidates = append(candidates, Candidate{
+			Type:       TypePassport,
+			Start:      start,
+			End:        end,
+			Confidence: 0.7,
+			Keywords:   passportKeywords,
+			Signals:    []string{"labelled as a passport number"},
+		})
+	}
+
+	for _, loc := range licenseRegex.FindAllStringSubmatchIndex(content, -1) {
+		start, end := loc[4], loc[5]
+		number := strings.ReplaceAll(content[start:end], "-", "")
+		if !strings.ContainsAny(number, "0123456789") {
+			continue
+		}
+
+		state := ""
+		if loc[2] >= 0 {
+			state = content[loc[2]:loc[3]]
+		} else {
+			state = licenseState(content, loc[0], end)
+		}
+
+		candidate := Candidate{
+			Type:       TypeDriversLicense,
+			Start:      start,
+			End:        end,
+			Confidence: 0.65,
+			Keywords:   licenseKeywords,
+			Country:    "US",
+			Normalized: number,
+		}
+		if format, known := licenseFormats[state]; known {
+			if format.MatchString(number) {
+				candidate.Validation = passed("matches the " + state + " license format")
+			} else {
+				candidate.Validation = downgraded("does not match the "+state+" license format", 0.5)
+			}
+			candidate.Signals = []string{"license number for " + state}
+		} else {
+			states := matchingStates(number)
+			if len(states) == 0 {
+				candidate.Validation = rejected("matches no US state license format")
+			}
+			candidate.Confidence = 0.5
+			candidate.Signals = []string{"license number format of " + strconv.Itoa(len(states)) + " states"}
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	for _, loc := range einRegex.FindAllStringIndex(content, -1) {
+		start, end := loc[0], loc[1]
+		value := content[start:end]
+		if !standalone(content, start, end) {
+			continue
+		}
+		// Bare 9-digit runs are only EINs when labelled
+		if !strings.Contains(value, "-") && nearbyKeyword(content, start, end, einKeywords) == "" {
+			continue
+		}
+		candidates = append(candidates, Candidate{
+			Type:       TypeEIN,
+			Start:      start,
+			End:        end,
+			Confidence: 0.55,
+			Keywords:   einKeywords,
+			Validation: ValidateEIN(value),
+			Country:    "US",
+			Signals:    []string{"EIN format"},
+		})
+	}
+
+	for _, loc := range itinRegex.FindAllStringIndex(content, -1) {
+		start, end := loc[0], loc[1]
+		value := content[start:end]
+		if !standalone(content, start, end) {
+			continue
+		}
+		if !strings.Contains(value, "-") && nearbyKeyword(content, start, end, itinKeywords) == "" {
+			continue
+		}
+		candidates = append(candidates, Candidate{
+			Type:       TypeITIN,
+			Start:      start,
+			End:        end,
+			Confidence: 0.6,
+			Keywords:   itinKeywords,
+			Validation: ValidateITIN(value),
+			Country:    "US",
+			Signals:    []string{"ITIN format"},
+		})
+	}
+
+	return candidates
+}
@@ -0,0 +1,321 @@
+package scan
+
+import (
+	"bufio"
+	"embed"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// nameFiles holds given-name and surname frequency lists, one pair of files
+// per locale: first_<locale>.txt and last_<locale>.txt. Each line is a
+// lower-case name followed by its approximate frequency per 100,000 people.
+//
+//go:embed data/names/*.txt
+var nameFiles embed.FS
+
+// nameInfo describes a dictionary name.
+type nameInfo struct {
+	frequency int
+	locales   []string
+}
+
+// nameDictionary holds the embedded name lists.
+type nameDictionary struct {
+	first map[string]nameInfo
+	last  map[string]nameInfo
+}
+
+var (
+	namesOnce sync.Once
+	names     *nameDictionary
+)
+
+// ambiguousNames are given names that are also common words, such as
+// "Will", "May" or "Grant"; matches starting with them are down-scored.
+var ambiguousNames = map[string]bool{
+	"will": true, "may": true, "june": true, "april": true, "august": true, "mark": true,
+	"grant": true, "bill": true, "rose": true, "hope": true, "faith": true, "grace": true,
+	"dawn": true, "summer": true, "ray": true, "guy": true, "chase": true, "hunter": true,
+	"art": true, "pat": true, "sue": true, "joy": true, "rich": true, "frank": true,
+	"jack": true, "lane": true, "page": true, "price": true, "long": true, "young": true,
+	"jean": true, "paul": true, "victor": true, "angel": true, "martin": true, "jordan": true,
+}
+
+// nameTitles are honorifics that introduce a name.
+var nameTitles = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "miss": true, "mx": true, "dr": true, "prof": true,
+	"sir": true, "dame": true, "herr": true, "frau": true, "sr": true, "sra": true,
+	"srta": true, "mme": true, "mlle": true, "sig": true, "dott": true,
+}
+
+// notNames are capitalized words that commonly follow a given name without
+// being a surname.
+var notNames = map[string]bool{
+	"the": true, "and": true, "or": true, "of": true, "street": true, "avenue": true,
+	"road": true, "inc": true, "ltd": true, "llc": true, "corp": true, "university": true,
+	"county": true, "day": true, "is": true, "was": true, "will": true, "may": true,
+}
+
+var nameKeywords = []string{"name", "customer", "patient", "employee", "contact", "signed", "author", "dear", "attn"}
+
+// nameFieldRegex matches field names that hold person names, with the
+// separator that introduces their value.
+var nameFieldRegex = regexp.MustCompile(`(?i)\b(first[_ -]?name|given[_ -]?name|forename|last[_ -]?name|surname|family[_ -]?name|full[_ -]?name|middle[_ -]?name|maiden[_ -]?name|customer[_ -]?name|contact[_ -]?name|display[_ -]?name|author|name)["']?\s*[:=]\s*["']?`)
+
+func init() {
+	RegisterDetector("names", DetectorFunc(detectNames))
+}
+
+// loadNames parses the embedded name lists once.
+func loadNames() *nameDictionary {
+	namesOnce.Do(func() {
+		names = &nameDictionary{
+			first: make(map[string]nameInfo),
+			last:  make(map[string]nameInfo),
+		}
+
+		files, _ := nameFiles.ReadDir("data/names")
+		for _, file := range files {
+			kind, locale, ok := strings.Cut(strings.TrimSuffix(file.Name(), ".txt"), "_")
+			if !ok {
+				continue
+			}
+			dict := names.first
+			if kind == "last" {
+				dict = names.last
+			}
+
+			f, err := nameFiles.Open(path.Join("data/names", file.Name()))
+			if err != nil {
+				continue
+			}
+			lines := bufio.NewScanner(f)
+			for lines.Scan() {
+				fields := strings.Fields(lines.Text())
+				if len(fields) != 2 || strings.HasPrefix(fields[0], "#") {
+					continue
+				}
+				frequency, _ := strconv.Atoi(fields[1])
+				info := dict[fields[0]]
+				info.frequency = max(info.frequency, frequency)
+				info.locales = append(info.locales, locale)
+				dict[fields[0]] = info
+			}
+			f.Close()
+		}
+	})
+	return names
+}
+
+// word is a run of letters in the scanned content.
+type word struct {
+	start, end int
+	text       string
+}
+
+// capitalized reports whether the word starts with an upper-case letter
+// followed by at least one lower-case letter.
+func (w word) capitalized() bool {
+	first, size := utf8.DecodeRuneInString(w.text)
+	if !unicode.IsUpper(first) {
+		return false
+	}
+	for _, r := range w.text[size:] {
+		if unicode.IsLower(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// initial reports whether the word is a single capital letter, as in "J.".
+func (w word) initial() bool {
+	r, size := utf8.DecodeRuneInString(w.text)
+	return size == len(w.text) && unicode.IsUpper(r)
+}
+
+// tokenizeWords splits content into words. Apostrophes and hyphens inside a
+// word are kept, so "O'Brien" and "Jean-Luc" are single words.
+func tokenizeWords(content string) []word {
+	words := make([]word, 0)
+	start := -1
+
+	for i, r := range content {
+		letter := unicode.IsLetter(r)
+		joiner := (r == '\'' || r == '’' || r == '-') && start >= 0
+		if letter || joiner {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			words = append(words, newWord(content, start, i))
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, newWord(content, start, len(content)))
+	}
+
+	return words
+}
+
+// newWord creates a word, trimming trailing joiners.
+func newWord(content string, start, end int) word {
+	text := strings.TrimRight(content[start:end], "'’-")
+	return word{start: start, end: start + len(text), text: text}
+}
+
+// joined reports whether two words are separated only by spaces, or by an
+// initial's full stop and spaces, on the same line.
+func joined(content string, a, b word) bool {
+	gap := content[a.end:b.start]
+	if a.initial() {
+		gap = strings.TrimPrefix(gap, ".")
+	}
+	return gap != "" && strings.Trim(gap, " \t") == ""
+}
+
+// detectNames finds person names using field-name hints, honorifics and
+// given-name/surname dictionaries.
+func detectNames(content string) []Candidate {
+	dict := loadNames()
+	words := tokenizeWords(content)
+	used := make([]bool, len(words))
+	candidates := make([]Candidate, 0)
+
+	emit := func(first, last int, confidence float64, reason string) {
+		for i := first; i <= last; i++ {
+			used[i] = true
+		}
+		candidates = append(candidates, Candidate{
+			Type:       TypeName,
+			Start:      words[first].start,
+			End:        words[last].end,
+			Confidence: confidence,
+			Keywords:   nameKeywords,
+			Signals:    []string{reason},
+		})
+	}
+
+	// extend returns the index of the last word of a name starting at i,
+	// following joined capitalized words and initials up to limit words.
+	extend := func(i, limit int) int {
+		last := i
+		for j := i + 1; j < len(words) && j-i < limit; j++ {
+			if used[j] || !joined(content, words[j-1], words[j]) {
+				break
+			}
+			if !words[j].capitalized() && !words[j].initial() {
+				break
+			}
+			if notNames[strings.ToLower(words[j].text)] {
+				break
+			}
+			last = j
+		}
+		// A name does not end with an initial
+		for last > i && words[last].initial() {
+			last--
+		}
+		return last
+	}
+
+	// Field-name hints such as first_name: "Ada"
+	for _, loc := range nameFieldRegex.FindAllStringSubmatchIndex(content, -1) {
+		i := sort.Search(len(words), func(i int) bool { return words[i].start >= loc[1] })
+		if i == len(words) || words[i].start != loc[1] || used[i] {
+			continue
+		}
+
+		field := strings.ToLower(content[loc[2]:loc[3]])
+		generic := field == "name" || field == "author"
+		if generic && !words[i].capitalized() {
+			continue
+		}
+
+		last := i
+		if words[i].capitalized() {
+			last = extend(i, 4)
+		}
+		if generic {
+			// Generic fields also name products, hosts and teams, so require
+			// dictionary support
+			_, knownFirst := dict.first[strings.ToLower(words[i].text)]
+			_, knownLast := dict.last[strings.ToLower(words[last].text)]
+			if !knownFirst && !(knownLast && last > i) {
+				continue
+			}
+		}
+
+		confidence := 0.85
+		if generic {
+			confidence = 0.7
+		}
+		emit(i, last, confidence, "value of field \""+content[loc[2]:loc[3]]+"\"")
+	}
+
+	for i := 0; i < len(words); i++ {
+		if used[i] {
+			continue
+		}
+		lower := strings.ToLower(words[i].text)
+
+		// Honorifics such as "Dr. Grace Hopper"
+		if nameTitles[lower] && i+1 < len(words) && !used[i+1] && words[i+1].capitalized() {
+			gap := strings.TrimPrefix(content[words[i].end:words[i+1].start], ".")
+			if gap != "" && strings.Trim(gap, " \t") == "" && !notNames[strings.ToLower(words[i+1].text)] {
+				used[i] = true
+				emit(i+1, extend(i+1, 3), 0.8, "preceded by title \""+words[i].text+"\"")
+				i++
+				continue
+			}
+		}
+
+		// Given name followed by a surname
+		first, known := dict.first[lower]
+		if !known || !words[i].capitalized() {
+			continue
+		}
+		last := extend(i, 3)
+		if last == i {
+			continue
+		}
+
+		surname := strings.ToLower(words[last].text)
+		_, knownSurname := dict.last[surname]
+		ambiguous := ambiguousNames[lower]
+		if ambiguous && !knownSurname {
+			continue
+		}
+
+		confidence := 0.45
+		reason := "given name \"" + words[i].text + "\""
+		if knownSurname {
+			confidence += 0.15
+			reason += " and surname \"" + words[last].text + "\" in name dictionaries"
+		} else {
+			reason += " in name dictionary"
+		}
+		if first.frequency >= 100 {
+			confidence += 0.1
+		}
+		if ambiguous {
+			confidence *= 0.5
+			reason += "; given name is also a common word"
+		}
+
+		emit(i, last, confidence, reason)
+		i = last
+	}
+
+	return candidates
+}
@@ -0,0 +1,489 @@
+package scan
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// National identifier types. Each is issued by one country, which detectors
+// record in PIIRecord.Country.
+const (
+	TypeUKNationalInsurance PIIType = "uk_nino"
+	TypeCanadianSIN         PIIType = "ca_sin"
+	TypeBrazilianCPF        PIIType = "br_cpf"
+	TypeBrazilianCNPJ       PIIType = "br_cnpj"
+	TypeIndianAadhaar       PIIType = "in_aadhaar"
+	TypeIndianPAN           PIIType = "in_pan"
+	TypeGermanTaxID         PIIType = "de_steuer_id"
+	TypeFrenchNIR           PIIType = "fr_nir"
+	TypeSpanishDNI          PIIType = "es_dni"
+	TypeSpanishNIE          PIIType = "es_nie"
+	TypeDutchBSN            PIIType = "nl_bsn"
+)
+
+// nationalIDFormat describes one kind of national identifier.
+type nationalIDFormat struct {
+	name       string
+	piiType    PIIType
+	country    string
+	regex      *regexp.Regexp
+	validate   Validator
+	keywords   []string
+	confidence float64
+	// Matches written as a bare run of letters and digits, without
+	// separators, are only reported next to a keyword, as such runs are
+	// common in other data
+	bareNeedsKeyword bool
+	// Matches that fail validation, or are only downgraded by it, are only
+	// reported next to a keyword
+	keywordUnlessValid bool
+}
+
+var nationalIDFormats = []nationalIDFormat{
+	{
+		name:       "UK National Insurance number",
+		piiType:    TypeUKNationalInsurance,
+		country:    "GB",
+		regex:      regexp.MustCompile(`\b[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z] ?[0-9]{2} ?[0-9]{2} ?[0-9]{2} ?[A-D]\b`),
+		validate:   ValidateNINO,
+		keywords:   []string{"nino", "national insurance", "ni number", "ni no"},
+		confidence: 0.6,
+	},
+	{
+		name:             "Canadian Social Insurance Number",
+		piiType:          TypeCanadianSIN,
+		country:          "CA",
+		regex:            regexp.MustCompile(`\b[0-9]{3}[ -]?[0-9]{3}[ -]?[0-9]{3}\b`),
+		validate:         ValidateSIN,
+		keywords:         []string{"sin", "social insurance", "nas", "assurance sociale"},
+		confidence:       0.5,
+		bareNeedsKeyword: true,
+	},
+	{
+		name:             "Brazilian CPF",
+		piiType:          TypeBrazilianCPF,
+		country:          "BR",
+		regex:            regexp.MustCompile(`\b[0-9]{3}\.?[0-9]{3}\.?[0-9]{3}-?[0-9]{2}\b`),
+		validate:         ValidateCPF,
+		keywords:         []string{"cpf", "cadastro de pessoas"},
+		confidence:       0.6,
+		bareNeedsKeyword: true,
+	},
+	{
+		name:             "Brazilian CNPJ",
+		piiType:          TypeBrazilianCNPJ,
+		country:          "BR",
+		regex:            regexp.MustCompile(`\b[0-9]{2}\.?[0-9]{3}\.?[0-9]{3}/?[0-9]{4}-?[0-9]{2}\b`),
+		validate:         ValidateCNPJ,
+		keywords:         []string{"cnpj"},
+		confidence:       0.6,
+		bareNeedsKeyword: true,
+	},
+	{
+		name:             "Indian Aadhaar number",
+		piiType:          TypeIndianAadhaar,
+		country:          "IN",
+		regex:            regexp.MustCompile(`\b[2-9][0-9]{3}[ -]?[0-9]{4}[ -]?[0-9]{4}\b`),
+		validate:         ValidateAadhaar,
+		keywords:         []string{"aadhaar", "aadhar", "uidai", "uid"},
+		confidence:       0.55,
+		bareNeedsKeyword: true,
+	},
+	{
+		name:             "Indian PAN",
+		piiType:          TypeIndianPAN,
+		country:          "IN",
+		regex:            regexp.MustCompile(`\b[A-Z]{3}[ABCFGHJLPT][A-Z][0-9]{4}[A-Z]\b`),
+		keywords:         []string{"pan", "permanent account", "income tax"},
+		confidence:       0.55,
+		bareNeedsKeyword: true,
+	},
+	{
+		name:             "German tax ID",
+		piiType:          TypeGermanTaxID,
+		country:          "DE",
+		regex:            regexp.MustCompile(`\b[1-9][0-9] ?[0-9]{3} ?[0-9]{3} ?[0-9]{3}\b`),
+		validate:         ValidateSteuerID,
+		keywords:         []string{"steuer-id", "steuerid", "steueridentifikationsnummer", "idnr", "tax id", "identifikationsnummer"},
+		confidence:       0.55,
+		bareNeedsKeyword: true,
+	},
+	{
+		name:       "French social security number",
+		piiType:    TypeFrenchNIR,
+		country:    "FR",
+		regex:      regexp.MustCompile(`\b[12] ?[0-9]{2} ?(?:0[1-9]|1[0-2]|[2-9][0-9]) ?(?:[0-9]{2}|2[ABab]) ?[0-9]{3} ?[0-9]{3} ?[0-9]{2}\b`),
+		validate:   ValidateNIR,
+		keywords:   []string{"nir", "sécurité sociale", "securite sociale", "insee", "numéro de sécu"},
+		confidence: 0.65,
+	},
+	{
+		name:               "Spanish DNI",
+		piiType:            TypeSpanishDNI,
+		country:            "ES",
+		regex:              regexp.MustCompile(`\b[0-9]{8}-?[A-Za-z]\b`),
+		validate:           ValidateDNI,
+		keywords:           []string{"dni", "nif", "documento nacional"},
+		confidence:         0.6,
+		keywordUnlessValid: true,
+	},
+	{
+		name:       "Spanish NIE",
+		piiType:    TypeSpanishNIE,
+		country:    "ES",
+		regex:      regexp.MustCompile(`\b[XYZxyz]-?[0-9]{7}-?[A-Za-z]\b`),
+		validate:   ValidateDNI,
+		keywords:   []string{"nie", "extranjero"},
+		confidence: 0.65,
+	},
+	{
+		name:             "Dutch BSN",
+		piiType:          TypeDutchBSN,
+		country:          "NL",
+		regex:            regexp.MustCompile(`\b[0-9]{4}\.?[0-9]{2}\.?[0-9]{3}\b`),
+		validate:         ValidateBSN,
+		keywords:         []string{"bsn", "burgerservicenummer", "sofinummer", "sofi"},
+		confidence:       0.5,
+		bareNeedsKeyword: true,
+	},
+}
+
+// nationalIDRegulations maps the country that issues a national identifier
+// to the privacy regulation governing it.
+var nationalIDRegulations = map[string]string{
+	"GB": "GDPR", // UK GDPR
+	"DE": "GDPR",
+	"FR": "GDPR",
+	"ES": "GDPR",
+	"NL": "GDPR",
+	"BR": "LGPD",
+	"CA": "PIPEDA",
+	"IN": "DPDP",
+}
+
+func init() {
+	RegisterDetector("national-ids", Triggered(Trigger{Digits: 2}, DetectorFunc(detectNationalIDs)))
+}
+
+// isNationalID reports whether piiType is a national identifier type.
+func isNationalID(piiType PIIType) bool {
+	for _, format := range nationalIDFormats {
+		if format.piiType == piiType {
+			return true
+		}
+	}
+	return false
+}
+
+// detectNationalIDs finds national identity and tax numbers.
+func detectNationalIDs(content string) []Candidate {
+	candidates := make([]Candidate, 0)
+
+	for _, format := range nationalIDFormats {
+		for _, loc := range format.regex.FindAllStringIndex(content, -1) {
+			start, end := loc[0], loc[1]
+			value := content[start:end]
+			if !standalone(content, start, end) || continuesGroups(content, start, end) {
+				continue
+			}
+
+			if format.bareNeedsKeyword && isAlphanumeric(value) &&
+				nearbyKeyword(content, start, end, format.keywords) == "" {
+				continue
+			}
+
+			candidate := Candidate{
+				Type:       format.piiType,
+				Start:      start,
+				End:        end,
+				Confidence: format.confidence,
+				Keywords:   format.keywords,
+				Country:    format.country,
+				Signals:    []string{format.name + " format"},
+			}
+			if format.validate != nil {
+				candidate.Validation = format.validate(value)
+			}
+			if format.keywordUnlessValid && candidate.Validation.Status != ValidationPassed &&
+				nearbyKeyword(content, start, end, format.keywords) == "" {
+				continue
+			}
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	return candidates
+}
+
+// isAlphanumeric reports whether s is made of ASCII letters and digits only.
+func isAlphanumeric(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !isDigit(c) && (c < 'A' || c > 'Z') && (c < 'a' || c > 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+// continuesGroups reports whether a match written in space-separated groups
+// is part of a longer run of groups, such as a card number
+// "4111 1111 1111 1111".
+func continuesGroups(content string, start, end int) bool {
+	if !strings.Contains(content[start:end], " ") {
+		return false
+	}
+	if start >= 2 && content[start-1] == ' ' && isDigit(content[start-2]) {
+		return true
+	}
+	return end+1 < len(content) && content[end] == ' ' && isDigit(content[end+1])
+}
+
+// ValidateNINO checks a UK National Insurance number against the prefixes
+// HMRC never allocates.
+func ValidateNINO(value string) Validation {
+	value = strings.ToUpper(strings.ReplaceAll(value, " ", ""))
+	if len(value) != 9 {
+		return rejected("not 9 characters")
+	}
+
+	switch prefix := value[:2]; prefix {
+	case "BG", "GB", "KN", "NK", "NT", "TN", "ZZ":
+		return rejected("prefix " + prefix + " is never allocated")
+	case "QQ":
+		return downgraded("QQ prefix is used in examples", 0.3)
+	}
+	return passed("valid National Insurance number prefix")
+}
+
+// ValidateSIN checks a Canadian Social Insurance Number with the Luhn
+// algorithm.
+func ValidateSIN(value string) Validation {
+	digits := digitsOf(value)
+	if len(digits) != 9 {
+		return rejected("not 9 digits")
+	}
+	if digits[0] == '0' || digits[0] == '8' {
+		return rejected("SINs do not start with " + digits[:1])
+	}
+	if !luhnValid(digits) {
+		return rejected("Luhn checksum failed")
+	}
+	return passed("Luhn checksum passed")
+}
+
+// ValidateCPF checks the two check digits of a Brazilian CPF.
+func ValidateCPF(value string) Validation {
+	digits := digitsOf(value)
+	if len(digits) != 11 {
+		return rejected("not 11 digits")
+	}
+	if strings.Count(digits, digits[:1]) == len(digits) {
+		return rejected("repeated digit")
+	}
+
+	for n := 9; n <= 10; n++ {
+		sum := 0
+		for i := 0; i < n; i++ {
+			sum += int(digits[i]-'0') * (n + 1 - i)
+		}
+		if check := sum * 10 % 11 % 10; check != int(digits[n]-'0') {
+			return rejected("check digits failed")
+		}
+	}
+	return passed("check digits passed")
+}
+
+// ValidateCNPJ checks the two check digits of a Brazilian CNPJ.
+func ValidateCNPJ(value string) Validation {
+	digits := digitsOf(value)
+	if len(digits) != 14 {
+		return rejected("not 14 digits")
+	}
+	if strings.Count(digits, digits[:1]) == len(digits) {
+		return rejected("repeated digit")
+	}
+
+	weights := []int{6, 5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2}
+	for n := 12; n <= 13; n++ {
+		sum := 0
+		for i := 0; i < n; i++ {
+			sum += int(digits[i]-'0') * weights[i+13-n]
+		}
+		check := 0
+		if sum%11 >= 2 {
+			check = 11 - sum%11
+		}
+		if check != int(digits[n]-'0') {
+			return rejected("check digits failed")
+		}
+	}
+	return passed("check digits passed")
+}
+
+// Verhoeff checksum tables.
+var (
+	verhoeffMultiply = [10][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 2, 3, 4, 0, 6, 7, 8, 9, 5},
+		{2, 3, 4, 0, 1, 7, 8, 9, 5, 6},
+		{3, 4, 0, 1, 2, 8, 9, 5, 6, 7},
+		{4, 0, 1, 2, 3, 9, 5, 6, 7, 8},
+		{5, 9, 8, 7, 6, 0, 4, 3, 2, 1},
+		{6, 5, 9, 8, 7, 1, 0, 4, 3, 2},
+		{7, 6, 5, 9, 8, 2, 1, 0, 4, 3},
+		{8, 7, 6, 5, 9, 3, 2, 1, 0, 4},
+		{9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+	}
+	verhoeffPermute = [8][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 5, 7, 6, 2, 8, 3, 0, 9, 4},
+		{5, 8, 0, 3, 7, 9, 6, 1, 4, 2},
+		{8, 9, 1, 6, 0, 4, 3, 5, 2, 7},
+		{9, 4, 5, 3, 1, 2, 6, 8, 7, 0},
+		{4, 2, 8, 6, 5, 7, 3, 9, 0, 1},
+		{2, 7, 9, 3, 8, 0, 6, 4, 1, 5},
+		{7, 0, 4, 6, 9, 1, 3, 2, 5, 8},
+	}
+)
+
+// ValidateAadhaar checks the Verhoeff check digit of an Indian Aadhaar
+// number.
+func ValidateAadhaar(value string) Validation {
+	digits := digitsOf(value)
+	if len(digits) != 12 {
+		return rejected("not 12 digits")
+	}
+	if digits[0] < '2' {
+		return rejected("Aadhaar numbers do not start with " + digits[:1])
+	}
+
+	check := 0
+	for i := 0; i < len(digits); i++ {
+		digit := int(digits[len(digits)-1-i] - '0')
+		check = verhoeffMultiply[check][verhoeffPermute[i%8][digit]]
+	}
+	if check != 0 {
+		return rejected("Verhoeff checksum failed")
+	}
+	return passed("Verhoeff checksum passed")
+}
+
+// ValidateSteuerID checks a German tax identification number: in the first
+// ten digits exactly one digit repeats, and the last digit is an ISO 7064
+// MOD 11,10 check digit.
+func ValidateSteuerID(value string) Validation {
+	digits := digitsOf(value)
+	if len(digits) != 11 {
+		return rejected("not 11 digits")
+	}
+
+	counts := make(map[byte]int)
+	for i := 0; i < 10; i++ {
+		counts[digits[i]]++
+	}
+	repeated := 0
+	for _, count := range counts {
+		if count > 3 {
+			return rejected("a digit occurs more than three times")
+		}
+		if count > 1 {
+			repeated++
+		}
+	}
+	if repeated != 1 {
+		return rejected("exactly one digit must repeat")
+	}
+
+	product := 10
+	for i := 0; i < 10; i++ {
+		sum := (int(digits[i]-'0') + product) % 10
+		if sum == 0 {
+			sum = 10
+		}
+		product = sum * 2 % 11
+	}
+	check := 11 - product
+	if check == 10 {
+		check = 0
+	}
+	if check != int(digits[10]-'0') {
+		return rejected("check digit failed")
+	}
+	return passed("check digit passed")
+}
+
+// ValidateNIR checks the mod-97 key of a French social security number.
+// Corsican department codes 2A and 2B count as 19 and 18.
+func ValidateNIR(value string) Validation {
+	value = strings.ToUpper(strings.ReplaceAll(value, " ", ""))
+	if len(value) != 15 {
+		return rejected("not 15 characters")
+	}
+
+	body := value[:13]
+	switch value[5:7] {
+	case "2A":
+		body = body[:5] + "19" + body[7:]
+	case "2B":
+		body = body[:5] + "18" + body[7:]
+	}
+
+	number, err := strconv.ParseUint(body, 10, 64)
+	if err != nil {
+		return rejected("not numeric")
+	}
+	key, _ := strconv.Atoi(value[13:])
+	if int(97-number%97) != key {
+		return rejected("key failed")
+	}
+	return passed("mod-97 key passed")
+}
+
+// dniLetters are the Spanish DNI check letters, indexed by number mod 23.
+const dniLetters = "TRWAGMYFPDXBNJZSQVHLCKE"
+
+// ValidateDNI checks the control letter of a Spanish DNI or NIE. An NIE's
+// leading X, Y or Z counts as 0, 1 or 2. The specimen number 12345678Z,
+// printed on sample cards, is downgraded.
+func ValidateDNI(value string) Validation {
+	value = strings.ToUpper(strings.ReplaceAll(value, "-", ""))
+	if len(value) != 9 {
+		return rejected("not 9 characters")
+	}
+
+	digits := value[:8]
+	if i := strings.IndexByte("XYZ", value[0]); i >= 0 {
+		digits = strconv.Itoa(i) + value[1:8]
+	}
+
+	number, err := strconv.Atoi(digits)
+	if err != nil {
+		return rejected("not numeric")
+	}
+	if dniLetters[number%23] != value[8] {
+		return rejected("control letter failed")
+	}
+	if value == "12345678Z" {
+		return downgraded("12345678Z is the specimen number", 0.3)
+	}
+	return passed("control letter passed")
+}
+
+// ValidateBSN checks a Dutch citizen service number with the 11-proof.
+func ValidateBSN(value string) Validation {
+	digits := digitsOf(value)
+	if len(digits) != 9 {
+		return rejected("not 9 digits")
+	}
+
+	sum := -int(digits[8] - '0')
+	for i := 0; i < 8; i++ {
+		sum += int(digits[i]-'0') * (9 - i)
+	}
+	if sum%11 != 0 || sum == 0 {
+		return rejected("11-proof failed")
+	}
+	return passed("11-proof passed")
+}
@@ -0,0 +1,281 @@
+package scan
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PatternPack is a set of user-defined detection patterns loaded from YAML.
+//
+//	replace_builtins: false
+//	patterns:
+//	  - name: Employee ID
+//	    regex: '\bEMP-[0-9]{6}\b'
+//	    type: employee_id
+//	    risk: HIGH
+//	    replacement: "[EMPLOYEE_ID]"
+//	    keywords: [employee, staff]
+//	    validators: [luhn]
+//	    confidence: 0.8
+//	  - name: IP Address
+//	    enabled: false
+//	detectors:
+//	  phones: false
+//
+// Packs are applied through Options.Packs. A pattern whose name matches a
+// built-in or earlier pattern overrides the fields it sets; "enabled: false"
+// removes it. Other patterns are added. Detectors are switched on or off by
+// their registered name.
+type PatternPack struct {
+	ReplaceBuiltins bool            `yaml:"replace_builtins"` // Drop the scanner's current patterns, built-ins included
+	Patterns        []PatternSpec   `yaml:"patterns"`
+	Detectors       map[string]bool `yaml:"detectors"`
+}
+
+// PatternSpec describes one pattern in a PatternPack.
+type PatternSpec struct {
+	Name        string   `yaml:"name"`
+	Regex       string   `yaml:"regex"`
+	Type        string   `yaml:"type"`
+	Risk        string   `yaml:"risk"`
+	Replacement string   `yaml:"replacement"`
+	Keywords    []string `yaml:"keywords"`
+	Validators  []string `yaml:"validators"`
+	Confidence  float64  `yaml:"confidence"`
+	Enabled     *bool    `yaml:"enabled"`
+}
+
+// riskLevels are the accepted values of PatternSpec.Risk.
+var riskLevels = map[string]bool{"CRITICAL": true, "HIGH": true, "MEDIUM": true, "LOW": true}
+
+// validators are the named validators pattern packs can reference. Like the
+// detector registry, they are guarded by registryMu.
+var validators = map[string]Validator{
+	"luhn":  ValidateLuhn,
+	"ssn":   ValidateSSN,
+	"ipv4":  ValidateIPv4,
+	"email": ValidateEmail,
+	"phone": ValidatePhone,
+
+	"nino":      ValidateNINO,
+	"sin":       ValidateSIN,
+	"cpf":       ValidateCPF,
+	"cnpj":      ValidateCNPJ,
+	"aadhaar":   ValidateAadhaar,
+	"steuer_id": ValidateSteuerID,
+	"nir":       ValidateNIR,
+	"dni":       ValidateDNI,
+	"bsn":       ValidateBSN,
+
+	"iban": ValidateIBAN,
+	"bic":  ValidateBIC,
+	"aba":  ValidateABA,
+
+	"mrz":  ValidateMRZ,
+	"ein":  ValidateEIN,
+	"itin": ValidateITIN,
+
+	"npi": ValidateNPI,
+	"dea": ValidateDEA,
+}
+
+// RegisterValidator makes a validator available to pattern packs by name.
+// It is intended to be called from init functions, but is safe to call
+// while scanners are built and packs are loaded.
+func RegisterValidator(name string, validator Validator) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	validators[strings.ToLower(name)] = validator
+}
+
+// registeredValidator returns the validator registered under name.
+func registeredValidator(name string) (Validator, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	validator, exists := validators[strings.ToLower(name)]
+	return validator, exists
+}
+
+// LoadPatternPack reads a pattern pack from a YAML file.
+func LoadPatternPack(path string) (*PatternPack, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pack, err := ParsePatternPack(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return pack, nil
+}
+
+// ParsePatternPack parses a YAML pattern pack. Unknown fields are an error so
+// that typos do not silently disable a pattern.
+func ParsePatternPack(data []byte) (*PatternPack, error) {
+	pack := &PatternPack{}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(pack); err != nil {
+		return nil, fmt.Errorf("invalid pattern pack: %w", err)
+	}
+
+	return pack, nil
+}
+
+// applyPatternPack compiles a pattern pack over patterns and returns the
+// result, switching detectors on or off as the pack says. Neither map is
+// changed if any pattern in the pack is invalid.
+func applyPatternPack(pack *PatternPack, patterns map[string]*Pattern, detectors map[string]Detector) (map[string]*Pattern, error) {
+	compiled := make(map[string]*Pattern, len(patterns))
+	if !pack.ReplaceBuiltins {
+		for name, pattern := range patterns {
+			compiled[name] = pattern
+		}
+	}
+
+	for i, spec := range pack.Patterns {
+		if spec.Name == "" {
+			return nil, fmt.Errorf("pattern %d: name is required", i+1)
+		}
+
+		if spec.Enabled != nil && !*spec.Enabled {
+			delete(compiled, spec.Name)
+			continue
+		}
+
+		pattern, err := spec.compile(compiled[spec.Name])
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", spec.Name, err)
+		}
+		compiled[spec.Name] = pattern
+	}
+
+	registered := registeredDetectors()
+	for _, name := range sortedKeys(pack.Detectors) {
+		if _, exists := registered[name]; !exists {
+			return nil, fmt.Errorf("unknown detector %q", name)
+		}
+	}
+
+	for name, enabled := range pack.Detectors {
+		if enabled {
+			detectors[name] = registered[name]
+		} else {
+			delete(detectors, name)
+		}
+	}
+	return compiled, nil
+}
+
+// compile builds a Pattern from the spec. When base is non-nil the spec
+// overrides it, and only the fields the spec sets are changed.
+func (spec PatternSpec) compile(base *Pattern) (*Pattern, error) {
+	pattern := &Pattern{Name: spec.Name}
+	if base != nil {
+		*pattern = *base
+	}
+
+	if spec.Regex != "" {
+		regex, err := regexp.Compile(spec.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+		pattern.Regex = regex
+		// The base pattern's trigger may not hold for the new regex
+		pattern.Trigger = nil
+	}
+	if pattern.Regex == nil {
+		return nil, fmt.Errorf("regex is required")
+	}
+
+	if spec.Type != "" {
+		pattern.PIIType = PIIType(strings.ToLower(spec.Type))
+	}
+	if pattern.PIIType == "" {
+		return nil, fmt.Errorf("type is required")
+	}
+
+	if spec.Risk != "" {
+		risk := strings.ToUpper(spec.Risk)
+		if !riskLevels[risk] {
+			return nil, fmt.Errorf("unknown risk level %q", spec.Risk)
+		}
+		pattern.RiskLevel = risk
+	}
+
+	if spec.Replacement != "" {
+		pattern.Replacement = spec.Replacement
+	}
+	if pattern.Replacement == "" {
+		pattern.Replacement = "[" + strings.ToUpper(string(pattern.PIIType)) + "]"
+	}
+
+	if spec.Keywords != nil {
+		pattern.Keywords = spec.Keywords
+	}
+
+	if spec.Confidence < 0 || spec.Confidence > 1 {
+		return nil, fmt.Errorf("confidence %v out of range 0-1", spec.Confidence)
+	}
+	if spec.Confidence > 0 {
+		pattern.Confidence = spec.Confidence
+	}
+
+	if spec.Validators != nil {
+		chain := make([]Validator, 0, len(spec.Validators))
+		for _, name := range spec.Validators {
+			validator, exists := registeredValidator(name)
+			if !exists {
+				return nil, fmt.Errorf("unknown validator %q", name)
+			}
+			chain = append(chain, validator)
+		}
+		pattern.Validate = chainValidators(chain)
+	}
+
+	return pattern, nil
+}
+
+// chainValidators runs validators in order. The first rejection wins;
+// otherwise failures multiply their confidence factors together.
+func chainValidators(chain []Validator) Validator {
+	if len(chain) == 0 {
+		return nil
+	}
+	if len(chain) == 1 {
+		return chain[0]
+	}
+
+	return func(value string) Validation {
+		combined := Validation{Status: ValidationPassed, Factor: 1.0}
+		reasons := make([]string, 0, len(chain))
+
+		for _, validate := range chain {
+			v := validate(value)
+			if v.Reject {
+				return v
+			}
+			if v.Status == ValidationFailed {
+				combined.Status = ValidationFailed
+				if v.Factor > 0 {
+					combined.Factor *= v.Factor
+				}
+			}
+			if v.Reason != "" {
+				reasons = append(reasons, v.Reason)
+			}
+		}
+
+		combined.Reason = strings.Join(reasons, "; ")
+		return combined
+	}
+}
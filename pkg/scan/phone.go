@@ -0,0 +1,251 @@
+package scan
+
+import (
+	_ "embed"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// callingCodeData lists the country calling codes the phone detector knows,
+// one per line: code, ISO 3166 region and the minimum and maximum length of
+// the national significant number.
+//
+//go:embed data/phone/calling_codes.txt
+var callingCodeData string
+
+// callingCode describes the numbers reached through one country calling code.
+type callingCode struct {
+	region    string
+	minLength int
+	maxLength int
+}
+
+var (
+	callingCodesOnce sync.Once
+	callingCodes     map[string]callingCode
+)
+
+// canadianAreaCodes are the NANP area codes assigned to Canada. Other NANP
+// numbers are attributed to the US.
+var canadianAreaCodes = map[string]bool{
+	"204": true, "226": true, "236": true, "249": true, "250": true, "263": true, "289": true,
+	"306": true, "343": true, "354": true, "365": true, "367": true, "368": true, "382": true,
+	"403": true, "416": true, "418": true, "428": true, "431": true, "437": true, "438": true,
+	"450": true, "468": true, "474": true, "506": true, "514": true, "519": true, "548": true,
+	"579": true, "581": true, "584": true, "587": true, "604": true, "613": true, "639": true,
+	"647": true, "672": true, "683": true, "705": true, "709": true, "742": true, "753": true,
+	"778": true, "780": true, "782": true, "807": true, "819": true, "825": true, "867": true,
+	"873": true, "879": true, "902": true, "905": true, "942": true,
+}
+
+var phoneKeywords = []string{"phone", "tel", "telephone", "mobile", "cell", "fax", "call", "whatsapp", "telefon", "telefono", "téléphone", "portable", "handy"}
+
+var (
+	// internationalPhoneRegex matches numbers written with a "+" or "00"
+	// international prefix, with common group separators.
+	internationalPhoneRegex = regexp.MustCompile(`(?:\+|\b00)[ \t]?[1-9](?:[ \t.()/-]{0,3}[0-9]){5,16}`)
+
+	// nanpPhoneRegex matches North American numbers in national format.
+	nanpPhoneRegex = regexp.MustCompile(`(?:\b1[ \t.-]?)?(?:\([2-9][0-9]{2}\)[ \t.-]?|\b[2-9][0-9]{2}[ \t.-]?)[2-9][0-9]{2}[ \t.-]?[0-9]{4}\b`)
+)
+
+func init() {
+	RegisterDetector("phones", Triggered(Trigger{Digits: 2}, DetectorFunc(detectPhones)))
+}
+
+// loadCallingCodes parses the embedded calling code table once.
+func loadCallingCodes() map[string]callingCode {
+	callingCodesOnce.Do(func() {
+		callingCodes = make(map[string]callingCode)
+		for _, line := range strings.Split(callingCodeData, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 4 || strings.HasPrefix(fields[0], "#") {
+				continue
+			}
+			minLength, _ := strconv.Atoi(fields[2])
+			maxLength, _ := strconv.Atoi(fields[3])
+			callingCodes[fields[0]] = callingCode{region: fields[1], minLength: minLength, maxLength: maxLength}
+		}
+	})
+	return callingCodes
+}
+
+// ValidatePhone checks that a phone number written in international format
+// has a known country calling code and a valid length for that country.
+// Numbers without an international prefix are checked as North American
+// numbers.
+func ValidatePhone(value string) Validation {
+	_, _, validation := parsePhone(value)
+	return validation
+}
+
+// parsePhone converts a phone number to E.164 form and infers its region.
+func parsePhone(value string) (string, string, Validation) {
+	value = strings.TrimSpace(value)
+	international := strings.HasPrefix(value, "+") || strings.HasPrefix(value, "00")
+
+	// A national trunk prefix is often written in parentheses after the
+	// country code, as in +44 (0)20 7946 0958
+	digits := digitsOf(strings.Replace(value, "(0)", "", 1))
+
+	if !international {
+		if len(digits) == 11 && digits[0] == '1' {
+			digits = digits[1:]
+		}
+		if len(digits) != 10 {
+			return "", "", rejected(strconv.Itoa(len(digits)) + " digits is not a valid North American number")
+		}
+		return checkNANP(digits)
+	}
+
+	if strings.HasPrefix(value, "00") {
+		digits = digits[2:]
+	}
+
+	codes := loadCallingCodes()
+	for n := 1; n <= 3 && n < len(digits); n++ {
+		code, exists := codes[digits[:n]]
+		if !exists {
+			continue
+		}
+		if n == 1 && digits[0] == '1' {
+			return checkNANP(digits[1:])
+		}
+
+		national := digits[n:]
+		// Italian numbers keep their leading zero; elsewhere it is a trunk
+		// prefix that does not belong in the international form
+		if len(national) > code.minLength && national[0] == '0' && code.region != "IT" {
+			national = national[1:]
+		}
+		if len(national) < code.minLength || len(national) > code.maxLength {
+			return "", code.region, rejected(strconv.Itoa(len(national)) + " digits is not a valid " + code.region + " number length")
+		}
+		return "+" + digits[:n] + national, code.region, passed("valid " + code.region + " number length")
+	}
+
+	return "", "", rejected("unknown country calling code")
+}
+
+// checkNANP checks a 10-digit North American number against the numbering
+// plan's area code and exchange rules.
+func checkNANP(digits string) (string, string, Validation) {
+	if len(digits) != 10 {
+		return "", "", rejected(strconv.Itoa(len(digits)) + " digits is not a valid North American number")
+	}
+
+	area, exchange, line := digits[:3], digits[3:6], digits[6:]
+	if area[0] < '2' || area[1:] == "11" {
+		return "", "", rejected("invalid area code " + area)
+	}
+	if exchange[0] < '2' || exchange[1:] == "11" {
+		return "", "", rejected("invalid exchange " + exchange)
+	}
+
+	region := "US"
+	if canadianAreaCodes[area] {
+		region = "CA"
+	}
+
+	normalized := "+1" + digits
+	if exchange == "555" && line[:2] == "01" {
+		return normalized, region, downgraded("555-01XX numbers are reserved for fiction", 0.3)
+	}
+	return normalized, region, passed("valid North American number")
+}
+
+// standalone reports whether content[start:end] is not part of a longer
+// run of digits, such as a serial number or a dotted or dashed identifier.
+func standalone(content string, start, end int) bool {
+	if start > 0 {
+		c := content[start-1]
+		if isDigit(c) || c == '+' || isLetter(c) {
+			return false
+		}
+		if (c == '-' || c == '.' || c == '/') && start > 1 && isDigit(content[start-2]) {
+			return false
+		}
+	}
+	if end < len(content) {
+		c := content[end]
+		if isDigit(c) || isLetter(c) {
+			return false
+		}
+		if (c == '-' || c == '.' || c == '/') && end+1 < len(content) && isDigit(content[end+1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// detectPhones finds phone numbers in international format and North
+// American numbers in national format, recording their E.164 form.
+func detectPhones(content string) []Candidate {
+	candidates := make([]Candidate, 0)
+
+	for _, loc := range internationalPhoneRegex.FindAllStringIndex(content, -1) {
+		start, end := loc[0], loc[1]
+		if !standalone(content, start, end) {
+			continue
+		}
+
+		normalized, region, validation := parsePhone(content[start:end])
+		candidates = append(candidates, Candidate{
+			Type:       TypePhone,
+			Start:      start,
+			End:        end,
+			Confidence: 0.7,
+			Keywords:   phoneKeywords,
+			Validation: validation,
+			Normalized: normalized,
+			Country:    region,
+			Signals:    []string{"international format"},
+		})
+	}
+
+	international := len(candidates)
+	overlaps := func(start, end int) bool {
+		for _, c := range candidates[:international] {
+			if start < c.End && c.Start < end {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, loc := range nanpPhoneRegex.FindAllStringIndex(content, -1) {
+		start, end := loc[0], loc[1]
+		if !standalone(content, start, end) || overlaps(start, end) {
+			continue
+		}
+
+		// Unformatted 10-digit runs are mostly order numbers, timestamps and
+		// IDs; only report them next to a phone keyword
+		confidence := 0.55
+		signal := "North American format"
+		if strings.Trim(content[start:end], "0123456789") == "" {
+			if nearbyKeyword(content, start, end, phoneKeywords) == "" {
+				continue
+			}
+			confidence = 0.45
+			signal = "unformatted digits"
+		}
+
+		normalized, region, validation := parsePhone(content[start:end])
+		candidates = append(candidates, Candidate{
+			Type:       TypePhone,
+			Start:      start,
+			End:        end,
+			Confidence: confidence,
+			Keywords:   phoneKeywords,
+			Validation: validation,
+			Normalized: normalized,
+			Country:    region,
+			Signals:    []string{signal},
+		})
+	}
+
+	return candidates
+}
@@ -0,0 +1,252 @@
+package scan
+
+import "strings"
+
+// prefilterContext is the number of bytes kept either side of a trigger
+// hit, widened to whole lines. It covers the longest match starting before
+// the hit and the keyword window around it.
+const prefilterContext = 128
+
+// Trigger says what text must contain for a pattern or detector to find
+// anything in it. The scanner runs a triggered pattern or detector only over
+// the lines around its trigger's hits, which skips most of a large input.
+type Trigger struct {
+	Literals []string // Case-insensitive ASCII strings, any of which fires the trigger at the start of a word
+	Digits   int      // Length of a run of ASCII digits that fires the trigger; 0 for none
+}
+
+// Triggered returns a detector that the scanner runs only around hits of
+// trigger. Its Detect method runs detector unchanged.
+func Triggered(trigger Trigger, detector Detector) Detector {
+	return triggeredDetector{Detector: detector, trigger: trigger}
+}
+
+type triggeredDetector struct {
+	Detector
+	trigger Trigger
+}
+
+// region is a span of content handed to a pattern or detector.
+type region struct {
+	start, end int
+}
+
+// prefilter finds the regions of content each stage of a scanner needs to
+// see, in a single pass over the content.
+type prefilter struct {
+	triggered     []bool // Whether each stage has a trigger
+	automaton     *ahoCorasick
+	literalStages [][]int // Stages fired by each literal
+	digitStages   [][]int // Stages fired by a digit run of each length
+}
+
+// newPrefilter builds a prefilter for stages with the given triggers; a nil
+// trigger means the stage sees all content.
+func newPrefilter(triggers []*Trigger) *prefilter {
+	p := &prefilter{triggered: make([]bool, len(triggers))}
+
+	index := make(map[string]int)
+	literals := make([]string, 0)
+	for stage, trigger := range triggers {
+		if trigger == nil {
+			continue
+		}
+		p.triggered[stage] = true
+
+		for _, literal := range trigger.Literals {
+			literal = strings.ToLower(literal)
+			if literal == "" {
+				continue
+			}
+			i, exists := index[literal]
+			if !exists {
+				i = len(literals)
+				index[literal] = i
+				literals = append(literals, literal)
+				p.literalStages = append(p.literalStages, nil)
+			}
+			p.literalStages[i] = append(p.literalStages[i], stage)
+		}
+
+		if trigger.Digits > 0 {
+			for len(p.digitStages) <= trigger.Digits {
+				p.digitStages = append(p.digitStages, nil)
+			}
+			p.digitStages[trigger.Digits] = append(p.digitStages[trigger.Digits], stage)
+		}
+	}
+
+	p.automaton = newAhoCorasick(literals)
+	return p
+}
+
+// regions returns, for each stage, the regions of content it must scan, in
+// order and without overlap. Stages without a trigger get all of content.
+func (p *prefilter) regions(content string) [][]region {
+	regions := make([][]region, len(p.triggered))
+	current := make([]region, len(p.triggered))
+	for stage := range current {
+		current[stage] = region{-1, -1}
+	}
+
+	hit := func(stage, pos int) {
+		cur := &current[stage]
+		if pos+prefilterContext <= cur.end {
+			return
+		}
+
+		from := max(0, pos-prefilterContext)
+		if cur.end < 0 || from > cur.end {
+			// Widen to the start of the line, unless that reaches back into
+			// the current region
+			last := max(cur.end, 0)
+			if i := strings.LastIndexByte(content[last:from], '\n'); i >= 0 {
+				from = last + i + 1
+			} else if cur.end >= 0 {
+				from = cur.end
+			} else {
+				from = 0
+			}
+		}
+		if cur.end >= 0 && from > cur.end {
+			regions[stage] = append(regions[stage], *cur)
+			cur.start = from
+		} else if cur.end < 0 {
+			cur.start = from
+		}
+
+		to := min(len(content), pos+prefilterContext)
+		if i := strings.IndexByte(content[to:], '\n'); i >= 0 {
+			to += i + 1
+		} else {
+			to = len(content)
+		}
+		cur.end = to
+	}
+
+	state, run := 0, 0
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+
+		if c >= '0' && c <= '9' {
+			run++
+			if run < len(p.digitStages) {
+				for _, stage := range p.digitStages[run] {
+					hit(stage, i)
+				}
+			}
+		} else {
+			run = 0
+		}
+
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		state = p.automaton.next[state][c]
+		for _, literal := range p.automaton.output[state] {
+			if !wordStart(content, i+1-p.automaton.lengths[literal]) {
+				continue
+			}
+			for _, stage := range p.literalStages[literal] {
+				hit(stage, i)
+			}
+		}
+	}
+
+	for stage, triggered := range p.triggered {
+		if !triggered {
+			regions[stage] = []region{{0, len(content)}}
+		} else if current[stage].end >= 0 {
+			regions[stage] = append(regions[stage], current[stage])
+		}
+	}
+	return regions
+}
+
+// fired reports whether regions, as returned by regions, hold a hit of any
+// trigger. It always holds when no stage has a trigger.
+func (p *prefilter) fired(regions [][]region) bool {
+	hasTrigger := false
+	for stage, triggered := range p.triggered {
+		if !triggered {
+			continue
+		}
+		if len(regions[stage]) > 0 {
+			return true
+		}
+		hasTrigger = true
+	}
+	return !hasTrigger
+}
+
+// wordStart reports whether a literal found at content[i:] starts a word,
+// or does not begin with a letter. Words start after a non-letter or at an
+// upper-case letter following a lower-case one, as in "userFingerprint", so
+// "voice" does not fire inside "invoice".
+func wordStart(content string, i int) bool {
+	if i == 0 || !isLetter(content[i]) || !isLetter(content[i-1]) {
+		return true
+	}
+	return content[i] >= 'A' && content[i] <= 'Z' && content[i-1] >= 'a' && content[i-1] <= 'z'
+}
+
+// ahoCorasick is an Aho-Corasick automaton matching lower-case literals.
+// Its transitions are complete, so scanning never follows failure links.
+type ahoCorasick struct {
+	next    [][256]int
+	output  [][]int // Literals ending at each state
+	lengths []int   // Length of each literal
+}
+
+// newAhoCorasick builds an automaton matching literals.
+func newAhoCorasick(literals []string) *ahoCorasick {
+	a := &ahoCorasick{next: make([][256]int, 1), output: make([][]int, 1)}
+	for c := range a.next[0] {
+		a.next[0][c] = -1
+	}
+
+	for i, literal := range literals {
+		a.lengths = append(a.lengths, len(literal))
+		state := 0
+		for j := 0; j < len(literal); j++ {
+			c := literal[j]
+			if a.next[state][c] < 0 {
+				a.next = append(a.next, [256]int{})
+				a.output = append(a.output, nil)
+				for k := range a.next[len(a.next)-1] {
+					a.next[len(a.next)-1][k] = -1
+				}
+				a.next[state][c] = len(a.next) - 1
+			}
+			state = a.next[state][c]
+		}
+		a.output[state] = append(a.output[state], i)
+	}
+
+	// Breadth-first, point missing transitions at the transitions of the
+	// failure state and inherit its outputs
+	fail := make([]int, len(a.next))
+	queue := make([]int, 0, len(a.next))
+	for c := range a.next[0] {
+		if s := a.next[0][c]; s < 0 {
+			a.next[0][c] = 0
+		} else {
+			queue = append(queue, s)
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		a.output[state] = append(a.output[state], a.output[fail[state]]...)
+		for c := range a.next[state] {
+			if s := a.next[state][c]; s < 0 {
+				a.next[state][c] = a.next[fail[state]][c]
+			} else {
+				fail[s] = a.next[fail[state]][c]
+				queue = append(queue, s)
+			}
+		}
+	}
+
+	return a
+}
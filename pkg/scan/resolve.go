@@ -0,0 +1,155 @@
+package scan
+
+import (
+	"sort"
+	"strings"
+)
+
+// Alternative is another type that matched a record's span but was ranked
+// below the reported type.
+type Alternative struct {
+	Type       PIIType
+	Confidence float64
+	Validation ValidationStatus
+}
+
+// validationRank orders validation outcomes: a passed check beats no check,
+// which beats a failed one.
+func validationRank(status ValidationStatus) int {
+	switch status {
+	case ValidationPassed:
+		return 2
+	case ValidationNone:
+		return 1
+	}
+	return 0
+}
+
+// outranks reports whether record a is a better reading of its span than b:
+// by validation, then confidence, then the longer, more specific match.
+func outranks(a, b *PIIRecord) bool {
+	if ra, rb := validationRank(a.Validation), validationRank(b.Validation); ra != rb {
+		return ra > rb
+	}
+	if a.Confidence != b.Confidence {
+		return a.Confidence > b.Confidence
+	}
+	if la, lb := a.End-a.Start, b.End-b.Start; la != lb {
+		return la > lb
+	}
+	return a.Type < b.Type
+}
+
+// conflicts reports whether two records are competing readings of the same
+// text. Records found in decoded text span the whole encoded token, so they
+// only conflict when one value contains the other.
+func conflicts(a, b *PIIRecord) bool {
+	if a.Start >= b.End || b.Start >= a.End {
+		return false
+	}
+	if len(a.Encoding) == 0 && len(b.Encoding) == 0 {
+		return true
+	}
+	return strings.Contains(a.Value, b.Value) || strings.Contains(b.Value, a.Value)
+}
+
+// resolveOverlaps keeps the best record of each group of conflicting
+// records, noting the types it beat as alternatives. Records must be sorted
+// by Start; kept records stay in that order.
+func resolveOverlaps(records []PIIRecord) (kept, suppressed []PIIRecord) {
+	kept = make([]PIIRecord, 0, len(records))
+
+	for i := 0; i < len(records); {
+		// Records overlapping each other, directly or through others, form
+		// a cluster that is resolved on its own
+		j, end := i+1, records[i].End
+		for ; j < len(records) && records[j].Start < end; j++ {
+			if records[j].End > end {
+				end = records[j].End
+			}
+		}
+		cluster := records[i:j]
+		i = j
+
+		ranked := make([]int, len(cluster))
+		for k := range ranked {
+			ranked[k] = k
+		}
+		sort.SliceStable(ranked, func(x, y int) bool {
+			return outranks(&cluster[ranked[x]], &cluster[ranked[y]])
+		})
+
+		winners := make([]int, 0, 1)
+		for _, k := range ranked {
+			candidate := &cluster[k]
+			beaten := false
+			for _, w := range winners {
+				winner := &cluster[w]
+				if !conflicts(winner, candidate) {
+					continue
+				}
+				beaten = true
+				if candidate.Type != winner.Type && !hasAlternative(winner, candidate.Type) {
+					winner.Alternatives = append(winner.Alternatives, Alternative{
+						Type:       candidate.Type,
+						Confidence: candidate.Confidence,
+						Validation: candidate.Validation,
+					})
+				}
+				break
+			}
+			if beaten {
+				suppressed = append(suppressed, *candidate)
+			} else {
+				winners = append(winners, k)
+			}
+		}
+
+		sort.Ints(winners)
+		for _, w := range winners {
+			kept = append(kept, cluster[w])
+		}
+	}
+
+	return kept, suppressed
+}
+
+// hasAlternative reports whether record already lists piiType as an
+// alternative.
+func hasAlternative(record *PIIRecord, piiType PIIType) bool {
+	for _, alternative := range record.Alternatives {
+		if alternative.Type == piiType {
+			return true
+		}
+	}
+	return false
+}
+
+// collapseDuplicates folds records with the same type and value into the
+// first of them, adding up occurrences and keeping the highest confidence.
+func collapseDuplicates(records []PIIRecord) []PIIRecord {
+	collapsed := make([]PIIRecord, 0, len(records))
+	index := make(map[string]int)
+
+	for _, record := range records {
+		value := record.Normalized
+		if value == "" {
+			value = record.Value
+		}
+		key := string(record.Type) + "\x00" + value
+
+		i, exists := index[key]
+		if !exists {
+			index[key] = len(collapsed)
+			collapsed = append(collapsed, record)
+			continue
+		}
+		first := &collapsed[i]
+		first.Occurrences += max(record.Occurrences, 1)
+		if record.Confidence > first.Confidence {
+			first.Confidence = record.Confidence
+		}
+	}
+
+	return collapsed
+}
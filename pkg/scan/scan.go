@@ -2,8 +2,12 @@
 package scan
 
 import (
+	"fmt"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // PIIType represents a type of personally identifiable information.
@@ -29,11 +33,24 @@ type PIIRecord struct {
 	Type        PIIType
 	Value       string
 	Location    string
-	Line        int
+	Start       int64 // Byte offset of the first byte of the match
+	End         int64 // Byte offset just past the match
+	Line        int   // 1-based line of the match start
+	Column      int   // 1-based column of the match start, in characters
 	Context     string
 	Confidence  float64
 	Redaction   string
 	RiskLevel   string
+	Validation  ValidationStatus // Outcome of the pattern's validator, if any
+	ValidationNote string        // Why the validator passed or failed
+	Signals     []string         // Evidence that raised or lowered Confidence
+	Normalized  string           // Canonical form of Value, such as E.164 for phone numbers
+	Country     string           // ISO 3166 region inferred from the value
+	Encoding    []string         // Encodings unwrapped to find Value, outermost first
+	Alternatives []Alternative   // Lower-ranked types that matched the same text
+	Occurrences int              // Number of identical findings this record stands for
+	Field       string           // Field holding the value in structured data, such as a CSV column
+	Row         int              // Data row of a table holding the value, counting from 1
 }
 
 // ScanResult contains scanning results.
@@ -42,11 +59,42 @@ type ScanResult struct {
 	PIIRecords    []PIIRecord
 	Summary       map[string]int
 	Compliance    map[string]string
+	Rejected      int // Candidates discarded by validators
+	LowConfidence int // Candidates below the scanner's minimum confidence
+	Suppressed    int // Matches overlapping a better-ranked match of the same text
+	Files         []FileInfo // Files scanned by the engine, in walk order
+	Columns       []ColumnSummary // Classification of the columns of tables
 }
 
+// FileInfo describes a file read by the engine. Text in other encodings is
+// transcoded to UTF-8 before scanning, so the Start and End of its records
+// are offsets into the UTF-8 text.
+type FileInfo struct {
+	Location   string
+	Type       string // Detected content type: "text", "binary" or a format such as "pdf"
+	Encoding   string // Encoding of a text file, such as "utf-8" or "utf-16le"
+	BOM        bool   // The text starts with a byte order mark
+	Skipped    bool   // Content that was not scanned
+	SkipReason string // Why it was not scanned: SkipBinary or SkipTooLarge
+}
+
+// Reasons a file is not scanned, recorded in FileInfo.SkipReason.
+const (
+	SkipBinary   = "binary"
+	SkipTooLarge = "too large to parse"
+)
+
 // Scanner scans for PII and privacy violations.
+// A Scanner is not modified after New returns it, so one scanner can be
+// shared by any number of goroutines.
 type Scanner struct {
-	patterns map[PIIType]*Pattern
+	stages        []Detector // Patterns sorted by PII type and name, then detectors sorted by name
+	prefilter     *prefilter // Selects the regions of content each stage scans
+	minConfidence float64
+	decodeDepth   int
+	hashDetection bool
+	collapseDuplicates bool
+	knownDigests  map[string]knownDigest // Digests of known values, keyed by hex
 }
 
 // Pattern defines a PII detection pattern.
@@ -55,109 +103,307 @@ type Pattern struct {
 	Regex       *regexp.Regexp
 	PIIType     PIIType
 	Replacement string
+	Validate    Validator // Optional check applied to every match
+	Keywords    []string  // Words that raise confidence when found near a match
+	Confidence  float64   // Base confidence of a match before context is considered
+	RiskLevel   string    // Overrides the risk level of PIIType when set
+	Trigger     *Trigger  // Limits the pattern to text around trigger hits; nil scans all content
 }
 
-// NewScanner creates a new privacy scanner.
-func NewScanner() *Scanner {
-	return &Scanner{
-		patterns: make(map[PIIType]*Pattern),
+// Options configures a scanner. The zero value gives the defaults.
+type Options struct {
+	MinConfidence float64 // Findings below this confidence are counted but not reported
+
+	// DecodeDepth is how many nested encodings are unwrapped before
+	// scanning. Zero uses DefaultDecodeDepth; a negative value scans content
+	// only as written.
+	DecodeDepth int
+
+	// HashDetection reports MD5, SHA-1 and SHA-256 digests of PII held in
+	// fields whose name says what was hashed, as in "email_sha256".
+	HashDetection bool
+
+	// KnownValues are PII values, such as a customer email list, whose
+	// unsalted digests are reported wherever they appear. Setting them
+	// enables HashDetection.
+	KnownValues []string
+
+	// CollapseDuplicates reports records of the same type and value once,
+	// counting their occurrences. Merge collapses across results too.
+	CollapseDuplicates bool
+
+	// Packs are applied in order on top of the built-in patterns.
+	Packs []*PatternPack
+
+	// Detectors are added to the registered detectors, replacing any with
+	// the same name. A nil detector removes the registered one.
+	Detectors map[string]Detector
+
+	// DisablePrefilter runs every pattern and detector over all content,
+	// ignoring triggers. It is slower and finds the same PII.
+	DisablePrefilter bool
+}
+
+// New builds a scanner. Patterns are compiled and detectors chosen once, so
+// scanning does no setup and the scanner is safe for concurrent use.
+func New(options Options) (*Scanner, error) {
+	patterns := make(map[string]*Pattern, len(builtinPatterns))
+	for _, pattern := range builtinPatterns {
+		patterns[pattern.Name] = pattern
+	}
+	detectors := registeredDetectors()
+
+	for i, pack := range options.Packs {
+		var err error
+		if patterns, err = applyPatternPack(pack, patterns, detectors); err != nil {
+			return nil, fmt.Errorf("pattern pack %d: %w", i+1, err)
+		}
+	}
+	for name, detector := range options.Detectors {
+		if detector == nil {
+			delete(detectors, name)
+		} else {
+			detectors[name] = detector
+		}
+	}
+
+	s := &Scanner{
+		minConfidence:      options.MinConfidence,
+		decodeDepth:        options.DecodeDepth,
+		hashDetection:      options.HashDetection || len(options.KnownValues) > 0,
+		knownDigests:       hashKnownValues(options.KnownValues),
+		collapseDuplicates: options.CollapseDuplicates,
 	}
+	if s.decodeDepth == 0 {
+		s.decodeDepth = DefaultDecodeDepth
+	} else if s.decodeDepth < 0 {
+		s.decodeDepth = 0
+	}
+
+	sorted := make([]*Pattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		sorted = append(sorted, pattern)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].PIIType != sorted[j].PIIType {
+			return sorted[i].PIIType < sorted[j].PIIType
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	triggers := make([]*Trigger, 0, len(sorted)+len(detectors))
+	for _, pattern := range sorted {
+		s.stages = append(s.stages, pattern)
+		triggers = append(triggers, pattern.Trigger)
+	}
+	for _, name := range sortedKeys(detectors) {
+		s.stages = append(s.stages, detectors[name])
+		var trigger *Trigger
+		if triggered, ok := detectors[name].(triggeredDetector); ok {
+			trigger = &triggered.trigger
+		}
+		triggers = append(triggers, trigger)
+	}
+	if options.DisablePrefilter {
+		triggers = make([]*Trigger, len(triggers))
+	}
+	s.prefilter = newPrefilter(triggers)
+
+	return s, nil
+}
+
+// NewScanner creates a privacy scanner with the default options.
+func NewScanner() *Scanner {
+	// The built-in patterns and registered detectors need no checking
+	s, _ := New(Options{})
+	return s
 }
 
-// InitializePatterns initializes PII detection patterns.
-func (s *Scanner) InitializePatterns() {
+// InitializePatterns used to reset a scanner to the built-in patterns. It
+// does nothing.
+//
+// Deprecated: patterns are compiled by New.
+func (s *Scanner) InitializePatterns() {}
+
+// builtinPatterns are the regex patterns every scanner starts from. They are
+// compiled once and shared, so they must not be modified.
+var builtinPatterns = []*Pattern{
 	// Email pattern
-	s.patterns[TypeEmail] = &Pattern{
+	{
 		Name:  "Email Address",
 		Regex: regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`),
 		PIIType: TypeEmail,
 		Replacement: "[EMAIL]",
-	}
-
-	// Phone number pattern (US format)
-	s.patterns[TypePhone] = &Pattern{
-		Name:  "Phone Number",
-		Regex: regexp.MustCompile(`\+?1?[-.\s]?\(?[0-9]{3}\)?[-.\s]?[0-9]{3}[-.\s]?[0-9]{4}`),
-		PIIType: TypePhone,
-		Replacement: "[PHONE]",
-	}
+		Validate: ValidateEmail,
+		Keywords: []string{"email", "e-mail", "mail", "contact"},
+		Confidence: 0.8,
+		Trigger: &Trigger{Literals: []string{"@"}},
+	},
 
 	// SSN pattern
-	s.patterns[TypeSSN] = &Pattern{
+	{
 		Name:  "Social Security Number",
 		Regex: regexp.MustCompile(`\b[0-9]{3}-[0-9]{2}-[0-9]{4}\b`),
 		PIIType: TypeSSN,
 		Replacement: "[SSN]",
-	}
+		Validate: ValidateSSN,
+		Keywords: []string{"ssn", "social security", "ss#"},
+		Confidence: 0.7,
+		Trigger: &Trigger{Digits: 4},
+	},
 
 	// Credit card pattern
-	s.patterns[TypeCreditCard] = &Pattern{
+	{
 		Name:  "Credit Card Number",
 		Regex: regexp.MustCompile(`\b(?:4[0-9]{12}(?:[0-9]{3})?|5[1-5][0-9]{14}|3[47][0-9]{13}|6(?:011|5[0-9]{2})[0-9]{12})\b`),
 		PIIType: TypeCreditCard,
 		Replacement: "[CC]",
-	}
+		Validate: ValidateLuhn,
+		Keywords: []string{"card", "card number", "credit card", "visa", "mastercard", "amex", "cvv"},
+		Confidence: 0.75,
+		Trigger: &Trigger{Digits: 13},
+	},
 
 	// IP address pattern
-	s.patterns[TypeIPAddress] = &Pattern{
+	{
 		Name:  "IP Address",
 		Regex: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\b`),
 		PIIType: TypeIPAddress,
 		Replacement: "[IP]",
-	}
+		Validate: ValidateIPv4,
+		Keywords: []string{"ip", "ip address", "client", "remote", "addr"},
+		Confidence: 0.6,
+		Trigger: &Trigger{Digits: 1},
+	},
 
 	// Bank account pattern
-	s.patterns[TypeBankAccount] = &Pattern{
+	{
 		Name:  "Bank Account Number",
 		Regex: regexp.MustCompile(`\b(?:ACC|Account|Bank)\s*[:\s]+[0-9]{8,15}\b`),
 		PIIType: TypeBankAccount,
 		Replacement: "[BANK]",
-	}
+		Keywords: []string{"iban", "routing", "sort code"},
+		Confidence: 0.8,
+		Trigger: &Trigger{Literals: []string{"acc", "bank"}},
+	},
 
 	// Medical record number pattern
-	s.patterns[TypeMedicalRecord] = &Pattern{
+	{
 		Name:  "Medical Record Number",
 		Regex: regexp.MustCompile(`\b(?:MRN|MedicalRecord|PatientID)\s*[:\s]+[A-Za-z0-9]{6,15}\b`),
 		PIIType: TypeMedicalRecord,
 		Replacement: "[MED]",
-	}
+		Keywords: []string{"patient", "medical", "diagnosis", "hospital"},
+		Confidence: 0.8,
+		Trigger: &Trigger{Literals: []string{"mrn", "medicalrecord", "patientid"}},
+	},
 
 	// Date of birth pattern
-	s.patterns[TypeDateOfBirth] = &Pattern{
+	{
 		Name:  "Date of Birth",
 		Regex: regexp.MustCompile(`\b(?:DOB|DateOfBirth|BirthDate)\s*[:\s]+(?:[0-9]{1,2}/[0-9]{1,2}/[0-9]{4}|[0-9]{4}-[0-9]{2}-[0-9]{2})\b`),
 		PIIType: TypeDateOfBirth,
 		Replacement: "[DOB]",
-	}
+		Keywords: []string{"birth", "born", "age"},
+		Confidence: 0.85,
+		Trigger: &Trigger{Literals: []string{"dob", "dateofbirth", "birthdate"}},
+	},
 }
 
 // Scan scans content for PII.
 func (s *Scanner) Scan(content, location string) *ScanResult {
+	return s.ScanRange(content, location, 0, len(content))
+}
+
+// ScanRange scans content for PII, keeping only matches that start at a byte
+// offset in [from, to). Callers scanning a large input as overlapping windows
+// use it to report each match exactly once.
+func (s *Scanner) ScanRange(content, location string, from, to int) *ScanResult {
 	result := &ScanResult{
 		PIIRecords: make([]PIIRecord, 0),
 		Summary:    make(map[string]int),
 		Compliance: make(map[string]string),
 	}
 
-	s.InitializePatterns()
+	candidates := s.detect(content)
+	if s.decodeDepth > 0 {
+		candidates = append(candidates, s.detectEncoded(content, s.decodeDepth)...)
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].Start < candidates[j].Start
+		})
+	}
+	if s.hashDetection {
+		candidates = append(candidates, s.detectHashes(content)...)
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].Start < candidates[j].Start
+		})
+	}
+
+	inRange := func(start int64) bool {
+		return start >= int64(from) && start < int64(to)
+	}
+
+	// Matches outside [from, to) are scored too, so that overlaps are
+	// resolved the same way by the windows on either side of a boundary
+	records := make([]PIIRecord, 0, len(candidates))
+	for _, candidate := range candidates {
+		validation := candidate.Validation
+		if validation.Reject {
+			if inRange(int64(candidate.Start)) {
+				result.Rejected++
+			}
+			continue
+		}
 
-	for _, pattern := range s.patterns {
-		matches := pattern.Regex.FindAllString(content, -1)
-		for _, match := range matches {
-			record := PIIRecord{
-				Type:       pattern.PIIType,
-				Value:      match,
-				Location:   location,
-				Context:    s.extractContext(content, match),
-				Confidence: 0.95,
-				Redaction:  pattern.Replacement,
-				RiskLevel:  getRiskLevel(pattern.PIIType),
+		confidence, signals := scoreMatch(content, candidate.Start, candidate.End, location, candidate.Confidence, candidate.Keywords, validation)
+		if confidence < s.minConfidence {
+			if inRange(int64(candidate.Start)) {
+				result.LowConfidence++
 			}
+			continue
+		}
+
+		records = append(records, PIIRecord{
+			Type:           candidate.Type,
+			Value:          candidate.Value,
+			Location:       location,
+			Start:          int64(candidate.Start),
+			End:            int64(candidate.End),
+			Context:        extractContext(content, candidate.Start, candidate.End),
+			Confidence:     confidence,
+			Redaction:      candidate.Redaction,
+			RiskLevel:      candidate.RiskLevel,
+			Validation:     validation.Status,
+			ValidationNote: validation.Reason,
+			Signals:        append(candidate.Signals, signals...),
+			Normalized:     candidate.Normalized,
+			Country:        candidate.Country,
+			Encoding:       candidate.Encoding,
+			Occurrences:    1,
+		})
+	}
+
+	kept, suppressed := resolveOverlaps(records)
+	for _, record := range suppressed {
+		if inRange(record.Start) {
+			result.Suppressed++
+		}
+	}
+	for _, record := range kept {
+		if inRange(record.Start) {
 			result.PIIRecords = append(result.PIIRecords, record)
-			result.Summary[string(pattern.PIIType)]++
 		}
 	}
 
+	// Candidates arrive in content order, as setLineColumns requires
+	setLineColumns(content, result.PIIRecords)
+
+	if s.collapseDuplicates {
+		result.PIIRecords = collapseDuplicates(result.PIIRecords)
+	}
+	for _, record := range result.PIIRecords {
+		result.Summary[string(record.Type)]++
+	}
 	result.TotalFound = len(result.PIIRecords)
 
 	// Calculate compliance status
@@ -166,17 +412,103 @@ func (s *Scanner) Scan(content, location string) *ScanResult {
 	return result
 }
 
-// extractContext extracts context around match.
-func (s *Scanner) extractContext(content, match string) string {
-	idx := strings.Index(content, match)
-	if idx == -1 {
-		return ""
+// Merge combines the results of several scans into one and recalculates
+// compliance over the combined findings.
+func (s *Scanner) Merge(results ...*ScanResult) *ScanResult {
+	merged := &ScanResult{
+		PIIRecords: make([]PIIRecord, 0),
+		Summary:    make(map[string]int),
+		Compliance: make(map[string]string),
+	}
+
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		merged.PIIRecords = append(merged.PIIRecords, result.PIIRecords...)
+		merged.Rejected += result.Rejected
+		merged.LowConfidence += result.LowConfidence
+		merged.Suppressed += result.Suppressed
+		merged.Files = append(merged.Files, result.Files...)
+		merged.Columns = append(merged.Columns, result.Columns...)
+	}
+
+	if s.collapseDuplicates {
+		merged.PIIRecords = collapseDuplicates(merged.PIIRecords)
+	}
+	for _, record := range merged.PIIRecords {
+		merged.Summary[string(record.Type)]++
+	}
+
+	merged.TotalFound = len(merged.PIIRecords)
+	merged.Compliance = s.calculateCompliance(merged)
+
+	return merged
+}
+
+// Offset moves the positions of all records by offset bytes. It is used for
+// results of scanning a window that starts partway through a larger input;
+// line and column give the position of the window's first byte.
+func (r *ScanResult) Offset(offset int64, line, column int) {
+	for i := range r.PIIRecords {
+		record := &r.PIIRecords[i]
+		record.Start += offset
+		record.End += offset
+		if record.Line == 1 {
+			record.Column += column - 1
+		}
+		record.Line += line - 1
+	}
+}
+
+// contextRadius is the number of bytes of context kept either side of a match.
+const contextRadius = 50
+
+// extractContext extracts context around the match at content[start:end].
+func extractContext(content string, start, end int) string {
+	from := max(0, start-contextRadius)
+	to := min(len(content), end+contextRadius)
+
+	// Avoid splitting a multi-byte character at either edge
+	for from > 0 && !utf8.RuneStart(content[from]) {
+		from--
 	}
+	for to < len(content) && !utf8.RuneStart(content[to]) {
+		to++
+	}
+
+	return content[from:to]
+}
 
-	start := max(0, idx-50)
-	end := min(len(content), idx+len(match)+50)
+// setLineColumns fills in Line and Column for records sorted by Start.
+func setLineColumns(content string, records []PIIRecord) {
+	line, lineStart, pos := 1, 0, 0
 
-	return content[start:end]
+	for i := range records {
+		start := int(records[i].Start)
+		for ; pos < start; pos++ {
+			if content[pos] == '\n' {
+				line++
+				lineStart = pos + 1
+			}
+		}
+		records[i].Line = line
+		records[i].Column = CountChars(content[lineStart:start]) + 1
+	}
+}
+
+// CountChars counts the UTF-8 characters in s, treating every byte that does
+// not continue a multi-byte sequence as the start of a character. Unlike
+// utf8.RuneCountInString it gives consistent counts when s is split midway
+// through a character.
+func CountChars(s string) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if utf8.RuneStart(s[i]) {
+			n++
+		}
+	}
+	return n
 }
 
 // calculateCompliance calculates compliance status.
@@ -196,7 +528,14 @@ func (s *Scanner) calculateCompliance(result *ScanResult) map[string]string {
 	// Check for high-risk PII
 	ssnCount := result.Summary[string(TypeSSN)]
 	creditCardCount := result.Summary[string(TypeCreditCard)]
-	medicalCount := result.Summary[string(TypeMedicalRecord)]
+	medicalCount := 0
+	for piiType, count := range result.Summary {
+		if isHealthData(PIIType(piiType)) {
+			medicalCount += count
+		}
+	}
+	biometricCount := result.Summary[string(TypeBiometric)]
+	providerCount := result.Summary[string(TypeNPI)] + result.Summary[string(TypeDEA)]
 
 	if ssnCount > 0 {
 		compliance["GDPR"] = "NON_COMPLIANT"
@@ -219,6 +558,31 @@ func (s *Scanner) calculateCompliance(result *ScanResult) map[string]string {
 		compliance["PCI-DSS"] = "N/A"
 	}
 
+	// Biometric data is a special category under GDPR
+	if biometricCount > 0 {
+		compliance["GDPR"] = "NON_COMPLIANT"
+	}
+
+	// Pseudonymized data is still personal data under GDPR
+	if result.Summary[string(TypePseudonymized)] > 0 && compliance["GDPR"] == "" {
+		compliance["GDPR"] = "AT_RISK"
+	}
+
+	// Provider identifiers alone are public, but point to healthcare data
+	if providerCount > 0 && compliance["HIPAA"] == "" {
+		compliance["HIPAA"] = "AT_RISK"
+	}
+
+	// National identifiers fall under the regulation of the issuing country
+	for _, record := range result.PIIRecords {
+		if !isNationalID(record.Type) {
+			continue
+		}
+		if regulation := nationalIDRegulations[record.Country]; regulation != "" {
+			compliance[regulation] = "NON_COMPLIANT"
+		}
+	}
+
 	// Default compliance
 	if compliance["GDPR"] == "" {
 		compliance["GDPR"] = "REVIEW"
@@ -251,6 +615,31 @@ func getRiskLevel(piitype PIIType) string {
 		TypeAddress:       "MEDIUM",
 		TypeFinancialInfo: "HIGH",
 		TypeBiometric:     "CRITICAL",
+
+		TypeUKNationalInsurance: "CRITICAL",
+		TypeCanadianSIN:         "CRITICAL",
+		TypeBrazilianCPF:        "CRITICAL",
+		TypeBrazilianCNPJ:       "MEDIUM",
+		TypeIndianAadhaar:       "CRITICAL",
+		TypeIndianPAN:           "HIGH",
+		TypeGermanTaxID:         "CRITICAL",
+		TypeFrenchNIR:           "CRITICAL",
+		TypeSpanishDNI:          "CRITICAL",
+		TypeSpanishNIE:          "CRITICAL",
+		TypeDutchBSN:            "CRITICAL",
+
+		TypePassport:       "CRITICAL",
+		TypeDriversLicense: "HIGH",
+		TypeEIN:            "MEDIUM",
+		TypeITIN:           "CRITICAL",
+
+		TypeDiagnosisCode: "HIGH",
+		TypeProcedureCode: "HIGH",
+		TypeHealthPlanID:  "HIGH",
+		TypeNPI:           "LOW",
+		TypeDEA:           "MEDIUM",
+
+		TypePseudonymized: "MEDIUM",
 	}
 
 	if level, exists := riskLevels[piitype]; exists {
@@ -280,39 +669,133 @@ func GenerateReport(result *ScanResult) string {
 	var report string
 
 	report += "=== Privacy Scanning Report ===\n\n"
-	report += "Total PII Found: " + string(rune(result.TotalFound+48)) + "\n\n"
+	report += "Total PII Found: " + strconv.Itoa(result.TotalFound) + "\n\n"
 
 	if result.TotalFound > 0 {
 		report += "PII Summary:\n"
-		for piiType, count := range result.Summary {
-			report += "  " + piiType + ": " + string(rune(count+48)) + "\n"
+		for _, piiType := range sortedKeys(result.Summary) {
+			report += "  " + piiType + ": " + strconv.Itoa(result.Summary[piiType]) + "\n"
 		}
 		report += "\n"
 
 		report += "Compliance Status:\n"
-		for regulation, status := range result.Compliance {
-			report += "  " + regulation + ": " + status + "\n"
+		for _, regulation := range sortedKeys(result.Compliance) {
+			report += "  " + regulation + ": " + result.Compliance[regulation] + "\n"
 		}
 		report += "\n"
 
+		classified := make([]ColumnSummary, 0)
+		for _, column := range result.Columns {
+			if column.Type != "" {
+				classified = append(classified, column)
+			}
+		}
+		if len(classified) > 0 {
+			report += "PII Columns:\n"
+			for _, column := range classified {
+				report += "  " + column.Location + " [" + column.Name + "]: " + string(column.Type) + " (" + column.Reason + ")\n"
+			}
+			report += "\n"
+		}
+
 		report += "Detailed Findings:\n"
 		for i, record := range result.PIIRecords {
 			if i >= 10 {
-				report += "  ... and " + string(rune(result.TotalFound-10+48)) + " more\n"
+				report += "  ... and " + strconv.Itoa(result.TotalFound-10) + " more\n"
 				break
 			}
-			report += "[" + string(rune(i+49)) + "] " + record.RiskLevel + " - " + string(record.Type) + "\n"
+			report += "[" + strconv.Itoa(i+1) + "] " + record.RiskLevel + " - " + string(record.Type) + "\n"
 			report += "    Value: " + record.Value[:min(len(record.Value), 20)] + "...\n"
-			report += "    Location: " + record.Location + "\n"
-			report += "    Redaction: " + record.Redaction + "\n\n"
+			report += "    Location: " + formatLocation(record) + "\n"
+			if record.Normalized != "" {
+				report += "    Normalized: " + record.Normalized
+				if record.Country != "" {
+					report += " (" + record.Country + ")"
+				}
+				report += "\n"
+			}
+			if len(record.Encoding) > 0 {
+				report += "    Encoding: " + strings.Join(record.Encoding, " > ") + "\n"
+			}
+			if record.Occurrences > 1 {
+				report += "    Occurrences: " + strconv.Itoa(record.Occurrences) + "\n"
+			}
+			if len(record.Alternatives) > 0 {
+				alternatives := make([]string, 0, len(record.Alternatives))
+				for _, alternative := range record.Alternatives {
+					alternatives = append(alternatives, string(alternative.Type))
+				}
+				report += "    Also matched: " + strings.Join(alternatives, ", ") + "\n"
+			}
+			report += "    Redaction: " + record.Redaction + "\n"
+			report += "    Confidence: " + strconv.FormatFloat(record.Confidence, 'f', 2, 64) + "\n"
+			if record.ValidationNote != "" {
+				report += "    Validation: " + string(record.Validation) + " (" + record.ValidationNote + ")\n"
+			}
+			report += "\n"
 		}
 	} else {
 		report += "✓ No PII detected\n"
 	}
 
+	if result.Rejected > 0 {
+		report += "\nCandidates rejected by validation: " + strconv.Itoa(result.Rejected) + "\n"
+	}
+	if result.LowConfidence > 0 {
+		report += "Candidates below confidence threshold: " + strconv.Itoa(result.LowConfidence) + "\n"
+	}
+	if result.Suppressed > 0 {
+		report += "Overlapping matches suppressed: " + strconv.Itoa(result.Suppressed) + "\n"
+	}
+
+	skipped, tooLarge, transcoded := 0, 0, 0
+	for _, file := range result.Files {
+		if file.Skipped && file.SkipReason == SkipTooLarge {
+			tooLarge++
+		} else if file.Skipped {
+			skipped++
+		} else if file.Encoding != "" && file.Encoding != "utf-8" {
+			transcoded++
+		}
+	}
+	if skipped > 0 {
+		report += "Binary files not scanned: " + strconv.Itoa(skipped) + "\n"
+	}
+	if tooLarge > 0 {
+		report += "Documents too large to parse: " + strconv.Itoa(tooLarge) + "\n"
+	}
+	if transcoded > 0 {
+		report += "Files transcoded to UTF-8: " + strconv.Itoa(transcoded) + "\n"
+	}
+
 	return report
 }
 
+// formatLocation formats a record's location as path:line:column, followed
+// by the row and field of values from structured data.
+func formatLocation(record PIIRecord) string {
+	location := record.Location
+	if record.Line > 0 {
+		location += ":" + strconv.Itoa(record.Line) + ":" + strconv.Itoa(record.Column)
+	}
+	if record.Row > 0 {
+		location += " (row " + strconv.Itoa(record.Row) + ", " + record.Field + ")"
+	} else if record.Field != "" {
+		location += " (" + record.Field + ")"
+	}
+	return location
+}
+
+// sortedKeys returns the keys of a map in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // GetComplianceStatus returns compliance status.
 func GetComplianceStatus(result *ScanResult, regulation string) string {
 	if status, exists := result.Compliance[regulation]; exists {
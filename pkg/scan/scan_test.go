@@ -0,0 +1,847 @@
+package scan
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newScanner builds a scanner with options, failing the test on error.
+func newScanner(t *testing.T, options Options) *Scanner {
+	t.Helper()
+	s, err := New(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestScanPositions(t *testing.T) {
+	content := "first: a@example.com\nsecond: é a@example.com trailing"
+	result := NewScanner().Scan(content, "file.txt")
+
+	if result.TotalFound != 2 {
+		t.Fatalf("TotalFound = %d, want 2", result.TotalFound)
+	}
+
+	tests := []struct {
+		start, end   int64
+		line, column int
+	}{
+		{7, 20, 1, 8},
+		{32, 45, 2, 11},
+	}
+	for i, tt := range tests {
+		r := result.PIIRecords[i]
+		if r.Start != tt.start || r.End != tt.end || r.Line != tt.line || r.Column != tt.column {
+			t.Errorf("record %d at %d-%d (%d:%d), want %d-%d (%d:%d)",
+				i, r.Start, r.End, r.Line, r.Column, tt.start, tt.end, tt.line, tt.column)
+		}
+		if content[r.Start:r.End] != r.Value {
+			t.Errorf("record %d value %q does not match content %q", i, r.Value, content[r.Start:r.End])
+		}
+	}
+
+	// Repeated values must get the context of their own occurrence
+	if !strings.Contains(result.PIIRecords[1].Context, "trailing") {
+		t.Errorf("second record context %q does not surround the second match", result.PIIRecords[1].Context)
+	}
+}
+
+func TestValidators(t *testing.T) {
+	tests := []struct {
+		name     string
+		validate Validator
+		value    string
+		status   ValidationStatus
+		reject   bool
+	}{
+		{"valid card", ValidateLuhn, "4111111111111111", ValidationPassed, false},
+		{"bad card", ValidateLuhn, "4111111111111112", ValidationFailed, true},
+		{"valid ssn", ValidateSSN, "536-22-1234", ValidationPassed, false},
+		{"ssn area 666", ValidateSSN, "666-22-1234", ValidationFailed, true},
+		{"ssn area 9xx", ValidateSSN, "912-22-1234", ValidationFailed, true},
+		{"ssn group 00", ValidateSSN, "536-00-1234", ValidationFailed, true},
+		{"public ip", ValidateIPv4, "8.8.4.4", ValidationPassed, false},
+		{"private ip", ValidateIPv4, "192.168.1.1", ValidationFailed, false},
+		{"loopback ip", ValidateIPv4, "127.0.0.1", ValidationFailed, true},
+		{"zero-padded ip", ValidateIPv4, "1.02.3.4", ValidationFailed, false},
+		{"email", ValidateEmail, "jane@example.co.uk", ValidationPassed, false},
+		{"retina asset", ValidateEmail, "icon@2x.png", ValidationFailed, true},
+		{"unknown tld", ValidateEmail, "jane@example.invalidtld", ValidationFailed, false},
+	}
+
+	for _, tt := range tests {
+		v := tt.validate(tt.value)
+		if v.Status != tt.status || v.Reject != tt.reject {
+			t.Errorf("%s: %q validated %s (reject %v), want %s (reject %v)", tt.name, tt.value, v.Status, v.Reject, tt.status, tt.reject)
+		}
+	}
+}
+
+func TestScanRejectsInvalidCandidates(t *testing.T) {
+	result := NewScanner().Scan("card 4111111111111112 ssn 666-12-3456 logo@2x.png", "file.txt")
+	for _, piiType := range []PIIType{TypeCreditCard, TypeSSN, TypeEmail} {
+		if n := result.Summary[string(piiType)]; n != 0 {
+			t.Errorf("found %d %s records, want 0", n, piiType)
+		}
+	}
+	if result.Rejected != 3 {
+		t.Errorf("Rejected = %d, want 3", result.Rejected)
+	}
+}
+
+func TestConfidenceScoring(t *testing.T) {
+	find := func(result *ScanResult, piiType PIIType) *PIIRecord {
+		for i := range result.PIIRecords {
+			if result.PIIRecords[i].Type == piiType {
+				return &result.PIIRecords[i]
+			}
+		}
+		t.Fatalf("no %s record found", piiType)
+		return nil
+	}
+
+	s := NewScanner()
+	plain := find(s.Scan("value 536-22-1234", "data.txt"), TypeSSN)
+	keyword := find(s.Scan("employee ssn: 536-22-1234", "data.txt"), TypeSSN)
+	fixture := find(s.Scan("value 536-22-1234", "testdata/data.txt"), TypeSSN)
+
+	if keyword.Confidence <= plain.Confidence {
+		t.Errorf("keyword confidence %.2f not above plain %.2f", keyword.Confidence, plain.Confidence)
+	}
+	if fixture.Confidence >= plain.Confidence {
+		t.Errorf("fixture confidence %.2f not below plain %.2f", fixture.Confidence, plain.Confidence)
+	}
+
+	for _, content := range []string{"release 8.8.8.8.1", "version: 1.2.3.4", "version = 1.2.3.4"} {
+		version := find(s.Scan(content, "data.txt"), TypeIPAddress)
+		if version.Confidence >= 0.5 {
+			t.Errorf("%q: version string confidence %.2f, want < 0.5", content, version.Confidence)
+		}
+	}
+
+	s = newScanner(t, Options{MinConfidence: 0.5})
+	result := s.Scan("release 8.8.8.8.1", "data.txt")
+	if result.Summary[string(TypeIPAddress)] != 0 || result.LowConfidence == 0 {
+		t.Errorf("low-confidence match not filtered: %+v", result)
+	}
+}
+
+func TestPatternPack(t *testing.T) {
+	pack, err := ParsePatternPack([]byte(`
+patterns:
+  - name: Employee ID
+    regex: '\bEMP-[0-9]{6}\b'
+    type: employee_id
+    risk: high
+    keywords: [employee]
+  - name: Email Address
+    replacement: "<redacted>"
+detectors:
+  phones: false
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newScanner(t, Options{Packs: []*PatternPack{pack}})
+
+	result := s.Scan("employee EMP-123456, call 415-555-2671 or mail a@example.com", "hr.txt")
+	if result.Summary["employee_id"] != 1 || result.Summary[string(TypePhone)] != 0 || result.Summary[string(TypeEmail)] != 1 {
+		t.Fatalf("unexpected summary %v", result.Summary)
+	}
+	for _, record := range result.PIIRecords {
+		if record.Type == "employee_id" && record.RiskLevel != "HIGH" {
+			t.Errorf("employee_id risk %q, want HIGH", record.RiskLevel)
+		}
+		if record.Type == TypeEmail && record.Redaction != "<redacted>" {
+			t.Errorf("email redaction %q, want <redacted>", record.Redaction)
+		}
+	}
+
+	for _, bad := range []string{
+		"patterns: [{name: x, regex: '(', type: t}]",
+		"patterns: [{name: x, regex: a}]",
+		"patterns: [{name: x, regex: a, type: t, validators: [nope]}]",
+		"patterns: [{name: x, regx: a, type: t}]",
+		"detectors: {nope: false}",
+	} {
+		pack, err := ParsePatternPack([]byte(bad))
+		if err == nil {
+			_, err = New(Options{Packs: []*PatternPack{pack}})
+		}
+		if err == nil {
+			t.Errorf("pack %q accepted, want error", bad)
+		}
+	}
+
+	// Registering validators races with neither building scanners nor
+	// loading packs
+	pack, err = ParsePatternPack([]byte("patterns: [{name: Card, regex: '[0-9]{16}', type: card, validators: [luhn]}]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			RegisterValidator(name, ValidateLuhn)
+			if _, err := New(Options{Packs: []*PatternPack{pack}}); err != nil {
+				t.Error(err)
+			}
+		}("test_" + string(rune('a'+i)))
+	}
+	wg.Wait()
+}
+
+func TestDetector(t *testing.T) {
+	employees := DetectorFunc(func(content string) []Candidate {
+		candidates := make([]Candidate, 0)
+		for _, name := range []string{"Ada Lovelace", "Alan Turing"} {
+			if i := strings.Index(content, name); i >= 0 {
+				candidates = append(candidates, Candidate{Type: TypeName, Start: i, End: i + len(name), Confidence: 0.9})
+			}
+		}
+		return candidates
+	})
+
+	s := newScanner(t, Options{Detectors: map[string]Detector{"names": nil, "employees": employees}})
+
+	result := s.Scan("owner: Alan Turing <alan@example.com>", "team.txt")
+	if result.Summary[string(TypeName)] != 1 || result.Summary[string(TypeEmail)] != 1 {
+		t.Fatalf("unexpected summary %v", result.Summary)
+	}
+
+	record := result.PIIRecords[0]
+	if record.Type != TypeName || record.Value != "Alan Turing" || record.Column != 8 ||
+		record.RiskLevel != "MEDIUM" || record.Redaction != "[NAME]" {
+		t.Errorf("unexpected record %+v", record)
+	}
+}
+
+func TestNameDetection(t *testing.T) {
+	content := `Dr. Grace Hopper met Jennifer Lopez and Will Power.
+{"first_name": "ada", "name": "Build Server", "surname": "Lovelace"}`
+
+	names := make([]string, 0)
+	for _, record := range NewScanner().Scan(content, "notes.txt").PIIRecords {
+		if record.Type == TypeName {
+			names = append(names, record.Value)
+		}
+	}
+
+	want := []string{"Grace Hopper", "Jennifer Lopez", "ada", "Lovelace"}
+	if strings.Join(names, "|") != strings.Join(want, "|") {
+		t.Errorf("found names %q, want %q", names, want)
+	}
+}
+
+func TestAddressDetection(t *testing.T) {
+	content := `Ship to: 1600 Pennsylvania Ave NW, Washington, DC 20500
+Office: 350 Fifth Avenue, Suite 3300
+New York, NY 10118-0110
+UK office: 10 Downing Street, London SW1A 2AA
+Residence: 24 Sussex Drive, Ottawa, ON K1M 1M4
+Büro: Hauptstraße 5, 10117 Berlin
+Bureau: 55 rue du Faubourg Saint-Honoré, 75008 Paris`
+
+	addresses := make([]string, 0)
+	for _, record := range NewScanner().Scan(content, "contacts.txt").PIIRecords {
+		if record.Type == TypeAddress {
+			addresses = append(addresses, record.Value)
+		}
+	}
+
+	want := []string{
+		"1600 Pennsylvania Ave NW, Washington, DC 20500",
+		"350 Fifth Avenue, Suite 3300\nNew York, NY 10118-0110",
+		"10 Downing Street, London SW1A 2AA",
+		"24 Sussex Drive, Ottawa, ON K1M 1M4",
+		"Hauptstraße 5, 10117 Berlin",
+		"55 rue du Faubourg Saint-Honoré, 75008 Paris",
+	}
+	if strings.Join(addresses, "|") != strings.Join(want, "|") {
+		t.Errorf("found addresses\n%q\nwant\n%q", addresses, want)
+	}
+
+	// Street suffixes in prose, without a keyword or a city and postal code
+	for _, content := range []string{
+		"The ruling by 9 Federal Court judges was upheld.",
+		"Read chapter 7 The Long Way before Friday.",
+		"We ranked 3 Market Place vendors by revenue.",
+		"Planning for Spring 2024 starts now.",
+		"Use String 5 as the default.",
+		"See Offering 3 in the catalog.",
+		"We shipped 3 route changes and a fix.",
+		"There are 2 place holders in the config.",
+		"She sang 5 avenue of the stars.",
+	} {
+		if result := NewScanner().Scan(content, "notes.txt"); result.Summary[string(TypeAddress)] != 0 {
+			t.Errorf("%q: found %d addresses, want 0", content, result.Summary[string(TypeAddress)])
+		}
+	}
+	for _, content := range []string{"Shipping address: 12 Harbour Way", "Anschrift: Lindenallee 12", "Adresse : 8 rue Victor Hugo"} {
+		if result := NewScanner().Scan(content, "notes.txt"); result.Summary[string(TypeAddress)] != 1 {
+			t.Errorf("%q: street address next to a keyword not found: %v", content, result.Summary)
+		}
+	}
+}
+
+func TestPhoneDetection(t *testing.T) {
+	content := `order 4155552671 shipped
+London: +44 (0)20 7946 0958
+Berlin: +49 30 901820
+Paris: 0033 1 42 68 53 00
+Tokyo: +81 3-1234-5678
+San Francisco: (415) 555-2671
+Toronto: 1-416-362-1234
+phone: 4155552672
+short: +44 20 7946 09
+unknown: +999 123 456 789`
+
+	result := NewScanner().Scan(content, "contacts.txt")
+
+	found := make([]string, 0)
+	for _, record := range result.PIIRecords {
+		if record.Type == TypePhone {
+			found = append(found, record.Normalized+" "+record.Country)
+		}
+	}
+
+	want := []string{
+		"+442079460958 GB",
+		"+4930901820 DE",
+		"+33142685300 FR",
+		"+81312345678 JP",
+		"+14155552671 US",
+		"+14163621234 CA",
+		"+14155552672 US",
+	}
+	if strings.Join(found, "|") != strings.Join(want, "|") {
+		t.Errorf("found phones\n%q\nwant\n%q", found, want)
+	}
+	if result.Rejected != 2 {
+		t.Errorf("Rejected = %d, want 2", result.Rejected)
+	}
+}
+
+func TestNationalIDs(t *testing.T) {
+	tests := []struct {
+		content string
+		piiType PIIType
+		country string
+	}{
+		{"NINO: AB 12 34 56 C", TypeUKNationalInsurance, "GB"},
+		{"SIN 130 692 544", TypeCanadianSIN, "CA"},
+		{"CPF 111.444.777-35", TypeBrazilianCPF, "BR"},
+		{"CNPJ 11.222.333/0001-81", TypeBrazilianCNPJ, "BR"},
+		{"Aadhaar: 2341 2341 2346", TypeIndianAadhaar, "IN"},
+		{"PAN ABCPE1234F", TypeIndianPAN, "IN"},
+		{"Steuer-ID 86095742719", TypeGermanTaxID, "DE"},
+		{"NIR 1 84 01 76 451 089 64", TypeFrenchNIR, "FR"},
+		{"DNI 87654321X", TypeSpanishDNI, "ES"},
+		{"order 87654321X", TypeSpanishDNI, "ES"},
+		{"NIE X1234567L", TypeSpanishNIE, "ES"},
+		{"BSN 111222333", TypeDutchBSN, "NL"},
+	}
+
+	s := newScanner(t, Options{Detectors: map[string]Detector{"phones": nil}})
+	for _, tt := range tests {
+		result := s.Scan(tt.content, "ids.txt")
+		if result.Summary[string(tt.piiType)] != 1 {
+			t.Errorf("%q: summary %v, want one %s", tt.content, result.Summary, tt.piiType)
+			continue
+		}
+		for _, record := range result.PIIRecords {
+			if record.Type == tt.piiType && (record.Country != tt.country || record.Validation == ValidationFailed) {
+				t.Errorf("%q: record %+v", tt.content, record)
+			}
+		}
+	}
+
+	// Wrong check digits, and bare runs and unvalidated DNIs without a keyword
+	for _, content := range []string{
+		"CPF 111.444.777-36", "DNI 12345678A", "ref 111222333", "NIR 1 84 01 76 451 089 65",
+		"order ABCPE1234F shipped", "SKU 12345678Z in stock", "ref 12345678A",
+	} {
+		if result := s.Scan(content, "ids.txt"); result.TotalFound != 0 {
+			t.Errorf("%q: found %v", content, result.Summary)
+		}
+	}
+
+	result := s.Scan("CPF 111.444.777-35, SIN 130 692 544", "ids.txt")
+	if result.Compliance["LGPD"] != "NON_COMPLIANT" || result.Compliance["PIPEDA"] != "NON_COMPLIANT" {
+		t.Errorf("compliance %v, want LGPD and PIPEDA NON_COMPLIANT", result.Compliance)
+	}
+}
+
+func TestFinancialDetection(t *testing.T) {
+	content := `order 021000021
+IBAN: GB82 WEST 1234 5698 7654 32 IBAN
+Konto DE89370400440532013000, FR14 2004 1010 0505 0001 3M02 606
+typo GB82 WEST 1234 5698 7654 33
+SWIFT: DEUTDEFF500, BIC NWBKGB2L, PASSWORD CRITICAL
+Routing number 021000021
+Sort code 20-00-00 account 12345678`
+
+	s := newScanner(t, Options{Detectors: map[string]Detector{"phones": nil, "national-ids": nil}})
+	result := s.Scan(content, "payments.txt")
+
+	found := make([]string, 0)
+	for _, record := range result.PIIRecords {
+		found = append(found, string(record.Type)+" "+record.Country+" "+record.Value)
+	}
+
+	want := []string{
+		"bank_account GB GB82 WEST 1234 5698 7654 32",
+		"bank_account DE DE89370400440532013000",
+		"bank_account FR FR14 2004 1010 0505 0001 3M02 606",
+		"financial_info DE DEUTDEFF500",
+		"financial_info GB NWBKGB2L",
+		"financial_info US 021000021",
+		"bank_account GB 20-00-00 account 12345678",
+	}
+	if strings.Join(found, "|") != strings.Join(want, "|") {
+		t.Errorf("found\n%q\nwant\n%q", found, want)
+	}
+	// The IBAN typo, and PASSWORD and CRITICAL, whose "country codes" do not exist
+	if result.Rejected != 3 {
+		t.Errorf("Rejected = %d, want 3", result.Rejected)
+	}
+}
+
+func TestIdentityDocuments(t *testing.T) {
+	content := `P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<
+L898902C36UTO7408122F1204159ZE184226B<<<<<10
+P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<
+L898902C46UTO7408122F1204159ZE184226B<<<<<10
+"passport_number": "533380006"
+Driver's license (CA): D1234567
+Texas driver license: 12345678
+Employer EIN 12-3456789
+ITIN 912-70-1234`
+
+	s := newScanner(t, Options{Detectors: map[string]Detector{"national-ids": nil, "financial": nil}})
+	result := s.Scan(content, "applicants.txt")
+
+	found := make([]string, 0)
+	for _, record := range result.PIIRecords {
+		if record.Type == TypeSSN {
+			continue
+		}
+		value := record.Normalized
+		if value == "" {
+			value = record.Value
+		}
+		found = append(found, string(record.Type)+" "+value)
+	}
+
+	want := []string{
+		"passport L898902C3",
+		"passport 533380006",
+		"drivers_license D1234567",
+		"drivers_license 12345678",
+		"ein 12-3456789",
+		"itin 912-70-1234",
+	}
+	if strings.Join(found, "|") != strings.Join(want, "|") {
+		t.Errorf("found\n%q\nwant\n%q", found, want)
+	}
+
+	for _, record := range result.PIIRecords {
+		if record.Type == TypeDriversLicense && record.Validation != ValidationPassed {
+			t.Errorf("license %q validation %s, want PASSED", record.Value, record.Validation)
+		}
+	}
+
+	// The second MRZ has a wrong document number check digit
+	if v := ValidateMRZ(content[90:179]); !v.Reject {
+		t.Errorf("MRZ with bad check digit accepted: %+v", v)
+	}
+}
+
+func TestHealthDetection(t *testing.T) {
+	vector := "[" + strings.Repeat("0.125, -0.5, ", 8) + "0.25]"
+	content := `Diagnosis: E11.9, CPT 99213
+NPI 1245319599, DEA number AB1234563
+Medicare MBI 1EG4-TE5-MK73, member id: XJH4482991
+{"face_encoding": ` + vector + `}
+fingerprint_template: ` + strings.Repeat("c0ffee", 16) + `
+order 99213 shipped
+tls:
+  fingerprint: ` + strings.Repeat("ab", 48)
+
+	s := newScanner(t, Options{Detectors: map[string]Detector{"national-ids": nil, "identity": nil, "phones": nil}})
+	result := s.Scan(content, "claims.txt")
+
+	found := make([]string, 0)
+	for _, record := range result.PIIRecords {
+		value := record.Value
+		if record.Type == TypeBiometric {
+			value = value[:1]
+		}
+		found = append(found, string(record.Type)+" "+value)
+	}
+
+	want := []string{
+		"diagnosis_code E11.9",
+		"procedure_code 99213",
+		"npi 1245319599",
+		"dea_number AB1234563",
+		"health_plan_id 1EG4-TE5-MK73",
+		"health_plan_id XJH4482991",
+		"biometric [",
+		"biometric c",
+	}
+	if strings.Join(found, "|") != strings.Join(want, "|") {
+		t.Errorf("found\n%q\nwant\n%q", found, want)
+	}
+	if result.Compliance["HIPAA"] != "NON_COMPLIANT" || result.Compliance["GDPR"] != "NON_COMPLIANT" {
+		t.Errorf("compliance %v, want HIPAA and GDPR NON_COMPLIANT", result.Compliance)
+	}
+
+	if v := ValidateNPI("1245319598"); !v.Reject {
+		t.Errorf("NPI with bad check digit accepted")
+	}
+	if v := ValidateDEA("AB1234564"); !v.Reject {
+		t.Errorf("DEA number with bad check digit accepted")
+	}
+
+	// A certificate fingerprint alone is not biometric data
+	config := "server:\n  cert: /etc/tls/server.pem\n  fingerprint: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08\n"
+	if result := s.Scan(config, "deploy.yaml"); result.TotalFound != 0 || result.Compliance["GDPR"] == "NON_COMPLIANT" {
+		t.Errorf("certificate fingerprint reported: %+v, compliance %v", result.PIIRecords, result.Compliance)
+	}
+
+	// Base64 blobs count only next to the name of a biometric template
+	blob := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("opaque binary payload ", 8)))
+	for _, content := range []string{
+		"spec:\n  template:\n    data: " + blob + "\n",
+		"face: " + blob + "\n",
+		"# SHA256 fingerprint: see below\n-----BEGIN CERTIFICATE-----\n" + blob + "\n-----END CERTIFICATE-----\n",
+	} {
+		if result := s.Scan(content, "manifest.yaml"); result.TotalFound != 0 {
+			t.Errorf("%q: found %+v", content[:20], result.PIIRecords)
+		}
+	}
+	if result := s.Scan("face embeddings of user 7\n"+blob, "export.txt"); result.Summary[string(TypeBiometric)] != 1 {
+		t.Errorf("blob next to face embeddings: summary %v, want one biometric", result.Summary)
+	}
+}
+
+func TestDecoding(t *testing.T) {
+	email := "jane.doe@acme-corp.com"
+	content := strings.Join([]string{
+		"b64=" + base64.StdEncoding.EncodeToString([]byte("contact: "+email)),
+		"url=jane.doe%40acme-corp.com",
+		"qp=jane.doe=40acme-corp.com",
+		`json="jane.doe\u0040acme-corp.com"`,
+		"hex=" + hex.EncodeToString([]byte(email)),
+		"nested=" + base64.StdEncoding.EncodeToString([]byte("to=jane.doe%40acme-corp.com")),
+	}, "\n")
+
+	s := NewScanner()
+	result := s.Scan(content, "export.txt")
+
+	found := make([]string, 0)
+	for _, record := range result.PIIRecords {
+		if record.Type != TypeEmail {
+			continue
+		}
+		if record.Value != email {
+			t.Errorf("decoded value %q, want %q", record.Value, email)
+		}
+		if content[record.Start:record.End] == email {
+			t.Errorf("record %+v does not cover the encoded span", record)
+		}
+		found = append(found, strings.Join(record.Encoding, ">"))
+	}
+
+	want := []string{"base64", "url", "quoted-printable", "unicode-escape", "hex", "base64>url"}
+	if strings.Join(found, "|") != strings.Join(want, "|") {
+		t.Errorf("found encodings %q, want %q", found, want)
+	}
+
+	s = newScanner(t, Options{DecodeDepth: 1})
+	if result := s.Scan(content, "export.txt"); result.Summary[string(TypeEmail)] != 5 {
+		t.Errorf("depth 1 found %d emails, want 5", result.Summary[string(TypeEmail)])
+	}
+	s = newScanner(t, Options{DecodeDepth: -1})
+	if result := s.Scan(content, "export.txt"); result.Summary[string(TypeEmail)] != 0 {
+		t.Errorf("decoding disabled found %d emails, want 0", result.Summary[string(TypeEmail)])
+	}
+
+	// Unpadded base64 of a length that is not a multiple of four is only
+	// decoded after a hint naming the encoding
+	s = NewScanner()
+	raw := base64.RawStdEncoding.EncodeToString([]byte("contact: " + email))
+	if result := s.Scan("id "+raw, "export.txt"); result.TotalFound != 0 {
+		t.Errorf("unhinted %q: found %+v", raw, result.PIIRecords)
+	}
+	if result := s.Scan("payload (base64): "+raw, "export.txt"); result.Summary[string(TypeEmail)] != 1 {
+		t.Errorf("hinted %q: summary %v, want one email", raw, result.Summary)
+	}
+
+	// Decoded text that fires no trigger is not scanned
+	letter := "Dear John Smith, thanks for the note"
+	if result := s.Scan(letter, "letter.txt"); result.Summary[string(TypeName)] != 1 {
+		t.Fatalf("plain letter: summary %v, want one name", result.Summary)
+	}
+	if result := s.Scan("msg "+base64.StdEncoding.EncodeToString([]byte(letter)), "export.txt"); result.TotalFound != 0 {
+		t.Errorf("decoded letter: found %+v", result.PIIRecords)
+	}
+}
+
+func TestHashedIdentifiers(t *testing.T) {
+	sha := sha256.Sum256([]byte("jane.doe@acme-corp.com"))
+	md := md5.Sum([]byte("4155552671"))
+	content := strings.Join([]string{
+		`"email_sha256": "` + hex.EncodeToString(sha[:]) + `"`,
+		"lookup " + hex.EncodeToString(md[:]),
+		"commit 2311bc6a1f0e4d7b9c8a5e3f2d1c0b9a8e7f6d5c",
+	}, "\n")
+
+	s := NewScanner()
+	if result := s.Scan(content, "export.txt"); result.Summary[string(TypePseudonymized)] != 0 {
+		t.Fatalf("hash detection is off by default, found %d", result.Summary[string(TypePseudonymized)])
+	}
+
+	s = newScanner(t, Options{HashDetection: true})
+	result := s.Scan(content, "export.txt")
+	if result.Summary[string(TypePseudonymized)] != 1 {
+		t.Fatalf("found %d hashes by field name, want 1: %+v", result.Summary[string(TypePseudonymized)], result.PIIRecords)
+	}
+	if result.Compliance["GDPR"] == "REVIEW" {
+		t.Errorf("GDPR status %q, want pseudonymized data treated as personal data", result.Compliance["GDPR"])
+	}
+
+	s = newScanner(t, Options{KnownValues: []string{"JANE.DOE@acme-corp.com", "(415) 555-2671"}})
+	result = s.Scan(content, "export.txt")
+	if result.Summary[string(TypePseudonymized)] != 2 {
+		t.Fatalf("found %d hashes with known values, want 2: %+v", result.Summary[string(TypePseudonymized)], result.PIIRecords)
+	}
+	for _, record := range result.PIIRecords {
+		if record.Validation != ValidationPassed {
+			t.Errorf("record %q not verified against known values: %s", record.Value, record.Validation)
+		}
+	}
+}
+
+func TestOverlapResolution(t *testing.T) {
+	// The bank account pattern also matches both lines, but the validated
+	// routing and phone numbers are better readings of the digits
+	content := "Bank: 021000021 routing\ncall phone Account: 2125551234\nphone 2125551234 again\n"
+
+	s := NewScanner()
+	result := s.Scan(content, "export.txt")
+	if result.TotalFound != 3 || result.Suppressed != 2 {
+		t.Fatalf("found %d and suppressed %d, want 3 and 2: %+v", result.TotalFound, result.Suppressed, result.PIIRecords)
+	}
+	if result.Summary[string(TypeBankAccount)] != 0 {
+		t.Errorf("summary counts suppressed matches: %v", result.Summary)
+	}
+	for _, record := range result.PIIRecords[:2] {
+		if len(record.Alternatives) != 1 || record.Alternatives[0].Type != TypeBankAccount {
+			t.Errorf("%s %q alternatives %+v, want bank_account", record.Type, record.Value, record.Alternatives)
+		}
+	}
+
+	s = newScanner(t, Options{CollapseDuplicates: true})
+	result = s.Scan(content, "export.txt")
+	if result.TotalFound != 2 || result.Summary[string(TypePhone)] != 1 {
+		t.Fatalf("collapsed to %d findings (%v), want 2", result.TotalFound, result.Summary)
+	}
+	if phone := result.PIIRecords[1]; phone.Occurrences != 2 || phone.Line != 2 {
+		t.Errorf("collapsed phone has %d occurrences at line %d, want 2 at line 2", phone.Occurrences, phone.Line)
+	}
+
+	merged := s.Merge(result, s.Scan(content, "copy.txt"))
+	if merged.TotalFound != 2 || merged.PIIRecords[1].Occurrences != 4 {
+		t.Errorf("merge collapsed to %d findings with %d phone occurrences, want 2 and 4", merged.TotalFound, merged.PIIRecords[1].Occurrences)
+	}
+}
+
+func TestTableColumns(t *testing.T) {
+	s := NewScanner()
+	header := []string{"customerEmail", "dob", "file_name", "notes"}
+	rows := [][]string{
+		{"ana@example.com", "1984-03-02", "a.txt", "call 415-872-3391"},
+		{"bo@example.org", "1990-11-23", "b.txt", ""},
+		{"not given", "1979-01-15", "c.txt", "none"},
+	}
+
+	table := NewTable("users.csv", header)
+	for i, row := range rows {
+		for j, text := range row {
+			value := Value{Text: text, Field: header[j], Hint: header[j], Row: i + 1, Offset: 100, Line: 7, Column: 3}
+			result := s.ScanValue(value, "users.csv")
+			for _, record := range result.PIIRecords {
+				if record.Row != i+1 || record.Field != header[j] || record.Line != 7 || record.Start < 100 {
+					t.Errorf("record %q at row %d %s, line %d, offset %d", record.Value, record.Row, record.Field, record.Line, record.Start)
+				}
+			}
+			table.Add(j, text, result)
+		}
+	}
+
+	want := []struct {
+		piiType PIIType
+		reason  string
+	}{
+		{TypeEmail, "66% of values are email addresses"},
+		{TypeDateOfBirth, "header names dates of birth"},
+		{"", ""},
+		{TypePhone, "50% of values are phone numbers"},
+	}
+	columns := table.Columns()
+	for i, column := range columns {
+		if column.Type != want[i].piiType || column.Reason != want[i].reason {
+			t.Errorf("column %s = %q (%s), want %q (%s)", column.Name, column.Type, column.Reason, want[i].piiType, want[i].reason)
+		}
+	}
+}
+
+// piiSamples are lines holding PII of every kind the scanner detects.
+var piiSamples = []string{
+	"contact jane.doe@acme-corp.com or +44 20 7946 0958",
+	"employee ssn: 536-22-8190, card number 4539148803436467",
+	"client ip 203.0.113.7 at 2024-03-01",
+	"Ship to: 1600 Pennsylvania Ave NW, Washington, DC 20500",
+	"UK office: 10 Downing Street, London SW1A 2AA",
+	"Dr. Grace Hopper met Jennifer Lopez",
+	"IBAN: GB82 WEST 1234 5698 7654 32, SWIFT: DEUTDEFF500",
+	"Routing number 021000021, sort code 20-00-00 account 12345678",
+	"CPF 111.444.777-35, SIN 130 692 544, BSN 111222333",
+	"P<UTOERIKSSON<<ANNA<MARIA<<<<<<<<<<<<<<<<<<<\nL898902C36UTO7408122F1204159ZE184226B<<<<<10",
+	"Driver's license (CA): D1234567, Employer EIN 12-3456789",
+	"Diagnosis: E11.9, NPI 1245319599, Medicare MBI 1EG4-TE5-MK73",
+	"patient MRN: 4481920 DOB: 1980-04-12 Account: 123456789",
+	`"fingerprint_template": "` + strings.Repeat("QUJDREVGR0hJSktMTU5PUA", 4) + `"`,
+}
+
+// proseSamples are lines without PII.
+var proseSamples = []string{
+	"The quarterly report covers shipping volumes and invoice processing times.",
+	"func (s *Service) Handle(ctx context.Context, req *Request) error {",
+	"	return fmt.Errorf(\"handle request: %w\", err)",
+	"Nothing to see on this line, just ordinary prose about warehouses.",
+	"# Configuration is loaded from the environment at startup.",
+}
+
+// syntheticCorpus returns at least size bytes of text in which one line in
+// every spacing holds PII and the rest are ordinary prose and code.
+func syntheticCorpus(size, spacing int) string {
+	var content strings.Builder
+	for i := 0; content.Len() < size; i++ {
+		if i%spacing == 0 {
+			content.WriteString(piiSamples[(i/spacing)%len(piiSamples)])
+		} else {
+			content.WriteString(proseSamples[i%len(proseSamples)])
+		}
+		content.WriteByte('\n')
+	}
+	return content.String()
+}
+
+func TestPrefilter(t *testing.T) {
+	full := newScanner(t, Options{DisablePrefilter: true})
+	s := NewScanner()
+
+	for _, spacing := range []int{1, 3, 40} {
+		content := syntheticCorpus(32<<10, spacing)
+		want := full.Scan(content, "data.txt")
+		got := s.Scan(content, "data.txt")
+
+		if len(want.Summary) < 15 {
+			t.Errorf("spacing %d: corpus only holds %d types of PII", spacing, len(want.Summary))
+		}
+		if got.TotalFound != want.TotalFound {
+			t.Errorf("spacing %d: prefilter found %d, want %d", spacing, got.TotalFound, want.TotalFound)
+			continue
+		}
+		for i, record := range got.PIIRecords {
+			w := want.PIIRecords[i]
+			if record.Type != w.Type || record.Start != w.Start || record.End != w.End || record.Confidence != w.Confidence {
+				t.Errorf("spacing %d: record %d = %s %q (%.2f), want %s %q (%.2f)",
+					spacing, i, record.Type, record.Value, record.Confidence, w.Type, w.Value, w.Confidence)
+				break
+			}
+		}
+	}
+}
+
+func TestConcurrentScan(t *testing.T) {
+	content := syntheticCorpus(4<<10, 2)
+	s := NewScanner()
+	want := s.Scan(content, "data.txt")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 3; j++ {
+				got := s.Scan(content, "data.txt")
+				if got.TotalFound != want.TotalFound || got.Suppressed != want.Suppressed {
+					t.Errorf("concurrent scan found %d (%d suppressed), want %d (%d)",
+						got.TotalFound, got.Suppressed, want.TotalFound, want.Suppressed)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// BenchmarkScan reports throughput on corpora with PII on every line, on one
+// line in ten and on one line in a hundred, with and without the prefilter.
+func BenchmarkScan(b *testing.B) {
+	for _, corpus := range []struct {
+		name    string
+		spacing int
+	}{{"dense", 1}, {"mixed", 10}, {"sparse", 100}} {
+		content := syntheticCorpus(1<<20, corpus.spacing)
+
+		for _, mode := range []struct {
+			name    string
+			options Options
+		}{{"prefilter", Options{}}, {"full", Options{DisablePrefilter: true}}} {
+			s, err := New(mode.options)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.Run(corpus.name+"/"+mode.name, func(b *testing.B) {
+				b.SetBytes(int64(len(content)))
+				for i := 0; i < b.N; i++ {
+					s.Scan(content, "data.txt")
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkScanParallel shares one scanner between goroutines.
+func BenchmarkScanParallel(b *testing.B) {
+	content := syntheticCorpus(1<<20, 10)
+	s := NewScanner()
+	b.SetBytes(int64(len(content)))
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			s.Scan(content, "data.txt")
+		}
+	})
+}
+
+func BenchmarkNew(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		NewScanner()
+	}
+}
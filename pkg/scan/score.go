@@ -0,0 +1,188 @@
+package scan
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Scoring weights. A match starts at its pattern's base confidence, gains
+// keywordBoost when a pattern keyword appears nearby and validatedBoost when
+// its validator passes, and is multiplied down by each weakening signal.
+const (
+	defaultConfidence = 0.6
+	keywordBoost      = 0.2
+	validatedBoost    = 0.1
+	maxConfidence     = 0.99
+
+	fixturePenalty = 0.6
+	embedPenalty   = 0.2
+	versionPenalty = 0.3
+
+	// Bytes searched for keywords before and after a match
+	keywordLookBehind = 64
+	keywordLookAhead  = 32
+)
+
+// fixtureDirs are path segments that mark test data rather than real data.
+var fixtureDirs = map[string]bool{
+	"test": true, "tests": true, "testdata": true, "__tests__": true, "spec": true,
+	"fixture": true, "fixtures": true, "mock": true, "mocks": true,
+	"example": true, "examples": true, "sample": true, "samples": true,
+}
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// scoreMatch computes the confidence that content[start:end] is real PII and
+// returns the signals that influenced the score.
+func scoreMatch(content string, start, end int, location string, base float64, keywords []string, validation Validation) (float64, []string) {
+	if base <= 0 {
+		base = defaultConfidence
+	}
+	score := base
+	signals := make([]string, 0)
+
+	if keyword := nearbyKeyword(content, start, end, keywords); keyword != "" {
+		score += keywordBoost
+		signals = append(signals, "keyword \""+keyword+"\" nearby")
+	}
+
+	switch validation.Status {
+	case ValidationPassed:
+		score += validatedBoost
+		signals = append(signals, validation.Reason)
+	case ValidationFailed:
+		if validation.Factor > 0 {
+			score *= validation.Factor
+		}
+		signals = append(signals, validation.Reason)
+	}
+
+	if isFixturePath(location) {
+		score *= fixturePenalty
+		signals = append(signals, "test fixture path")
+	}
+
+	if embedded := embeddedIn(content, start, end); embedded != "" {
+		score *= embedPenalty
+		signals = append(signals, "part of a "+embedded)
+	}
+
+	if looksLikeVersion(content, start, end) {
+		score *= versionPenalty
+		signals = append(signals, "looks like a version string")
+	}
+
+	if score > maxConfidence {
+		score = maxConfidence
+	}
+	return score, signals
+}
+
+// nearbyKeyword returns the first keyword found around content[start:end].
+func nearbyKeyword(content string, start, end int, keywords []string) string {
+	if len(keywords) == 0 {
+		return ""
+	}
+
+	window := strings.ToLower(content[max(0, start-keywordLookBehind):start] + " " +
+		content[end:min(len(content), end+keywordLookAhead)])
+	for _, keyword := range keywords {
+		if containsWord(window, strings.ToLower(keyword)) {
+			return keyword
+		}
+	}
+	return ""
+}
+
+// containsWord reports whether word occurs in s without letters directly
+// before or after it, so "ip" matches "client ip:" but not "zip".
+func containsWord(s, word string) bool {
+	for offset := 0; ; {
+		i := strings.Index(s[offset:], word)
+		if i < 0 {
+			return false
+		}
+		i += offset
+		j := i + len(word)
+		if (i == 0 || !isLetter(s[i-1])) && (j == len(s) || !isLetter(s[j])) {
+			return true
+		}
+		offset = i + 1
+	}
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isFixturePath reports whether a location lies in a test or example directory,
+// or is itself a test file.
+func isFixturePath(location string) bool {
+	location = strings.ToLower(strings.ReplaceAll(location, "\\", "/"))
+	for _, segment := range strings.Split(path.Dir(location), "/") {
+		if fixtureDirs[segment] {
+			return true
+		}
+	}
+
+	base := path.Base(location)
+	return strings.Contains(base, "_test.") || strings.Contains(base, ".test.") ||
+		strings.Contains(base, ".spec.") || strings.HasPrefix(base, "test_")
+}
+
+// embeddedIn reports whether the match is part of a larger UUID or hex
+// digest, returning a description of the enclosing token.
+func embeddedIn(content string, start, end int) string {
+	from, to := start, end
+	for from > 0 && isHexOrDash(content[from-1]) {
+		from--
+	}
+	for to < len(content) && isHexOrDash(content[to]) {
+		to++
+	}
+	if from == start && to == end {
+		return ""
+	}
+
+	token := content[from:to]
+	if uuidRegex.MatchString(token) {
+		return "UUID"
+	}
+	if len(token) >= 32 && !strings.Contains(token, "-") {
+		return "hex digest"
+	}
+	return ""
+}
+
+// looksLikeVersion reports whether a dotted match is a version number, such as
+// "v1.2.3.4", "version 10.0.0.1" or one component of "1.2.3.4.5".
+func looksLikeVersion(content string, start, end int) bool {
+	value := content[start:end]
+	if !strings.Contains(value, ".") || strings.Trim(value, "0123456789.") != "" {
+		return false
+	}
+
+	// Wide enough for a key and its separator, as in "version = "
+	before := strings.ToLower(content[max(0, start-16):start])
+	if strings.HasSuffix(before, "v") || strings.HasSuffix(strings.TrimRight(before, " \t:=\"'"), "version") {
+		return true
+	}
+
+	// Extra dotted components on either side
+	if start >= 2 && content[start-1] == '.' && isDigit(content[start-2]) {
+		return true
+	}
+	if end+1 < len(content) && content[end] == '.' && isDigit(content[end+1]) {
+		return true
+	}
+	return false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexOrDash(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') || c == '-'
+}
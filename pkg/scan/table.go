@@ -0,0 +1,238 @@
+package scan
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Value is a value read from structured data, such as a CSV cell, to be
+// scanned on its own with the name of its field as keyword context.
+type Value struct {
+	Text   string
+	Field  string // Where the value is, such as a column header, JSON path or "Sheet1!B7"
+	Hint   string // Label scanned before Text as keyword context, such as a column header or JSON key
+	Row    int    // Data row of a table, counting from 1; 0 outside tables
+	Offset int64  // Byte offset of Text in the file
+	Line   int    // Line of the first character of Text, counting from 1; 0 if Text has no position in the file
+	Column int    // Column of the first character of Text, in characters
+}
+
+// ScanValue scans a single value. The value is preceded by its hint, so that
+// a column called "ssn" raises the confidence of the numbers below it as a
+// label next to them would. Records carry the value's field and row, and are
+// positioned in the file; for values without a position, their Start and
+// End are offsets into Text and their Line is 0.
+func (s *Scanner) ScanValue(value Value, location string) *ScanResult {
+	prefix := ""
+	if words := fieldWords(value.Hint); len(words) > 0 {
+		prefix = strings.Join(words, " ") + ": "
+	}
+	content := prefix + value.Text
+
+	result := s.ScanRange(content, location, len(prefix), len(content))
+	if value.Line > 0 {
+		result.Offset(value.Offset-int64(len(prefix)), value.Line, value.Column-CountChars(prefix))
+	}
+	for i := range result.PIIRecords {
+		record := &result.PIIRecords[i]
+		record.Field = value.Field
+		record.Row = value.Row
+		if value.Line == 0 {
+			record.Start -= int64(len(prefix))
+			record.End -= int64(len(prefix))
+			record.Line, record.Column = 0, 0
+		}
+	}
+	return result
+}
+
+// fieldWords splits a field name such as "customer_email" or "dateOfBirth"
+// into lower-case words.
+func fieldWords(name string) []string {
+	words := make([]string, 0)
+	word := make([]rune, 0)
+	var prev rune
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) || unicode.IsUpper(r) && unicode.IsLower(prev) {
+			if len(word) > 0 {
+				words = append(words, strings.ToLower(string(word)))
+				word = word[:0]
+			}
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			word = append(word, r)
+		}
+		prev = r
+	}
+	if len(word) > 0 {
+		words = append(words, strings.ToLower(string(word)))
+	}
+	return words
+}
+
+// columnShare is the share of a column's values that must hold one type of
+// PII for the column to be classified by its values alone.
+const columnShare = 0.5
+
+// headerNames lists column names, as words joined by spaces, that name the
+// PII their columns hold. A header matches by its last one, two or three
+// words, so "customer email" and "billing address line" match too.
+var headerNames = []struct {
+	piiType PIIType
+	names   []string
+}{
+	{TypeEmail, []string{"email", "e mail", "email address", "mail"}},
+	{TypePhone, []string{"phone", "phone number", "telephone", "tel", "mobile", "cell", "msisdn"}},
+	{TypeSSN, []string{"ssn", "social security", "social security number"}},
+	{TypeName, []string{"first name", "last name", "full name", "surname", "given name", "family name", "middle name", "forename"}},
+	{TypeDateOfBirth, []string{"dob", "date of birth", "birth date", "birthdate", "birthday"}},
+	{TypeAddress, []string{"address", "address line", "street", "street address", "zip", "zip code", "zipcode", "postcode", "postal code"}},
+	{TypeIPAddress, []string{"ip", "ip address"}},
+	{TypeBankAccount, []string{"iban", "account number", "bank account", "routing number"}},
+	{TypeCreditCard, []string{"credit card", "card number", "cc number"}},
+	{TypePassport, []string{"passport", "passport number"}},
+	{TypeDriversLicense, []string{"drivers license", "driver license"}},
+	{TypeMedicalRecord, []string{"mrn", "medical record number", "patient id"}},
+	{TypeDiagnosisCode, []string{"diagnosis", "diagnosis code"}},
+}
+
+// typeNouns names the values of common PII types in column summaries.
+var typeNouns = map[PIIType]string{
+	TypeEmail:         "email addresses",
+	TypePhone:         "phone numbers",
+	TypeSSN:           "SSNs",
+	TypeCreditCard:    "credit card numbers",
+	TypeBankAccount:   "bank account numbers",
+	TypeIPAddress:     "IP addresses",
+	TypeName:          "names",
+	TypeDateOfBirth:   "dates of birth",
+	TypeAddress:       "addresses",
+	TypeMedicalRecord: "medical record numbers",
+}
+
+// headerType returns the PII a column holds judging by its name.
+func headerType(name string) PIIType {
+	words := fieldWords(name)
+
+	// A bare "name" column holds names, but "file name" does not
+	if len(words) == 1 && words[0] == "name" {
+		return TypeName
+	}
+	for n := min(3, len(words)); n > 0; n-- {
+		suffix := strings.Join(words[len(words)-n:], " ")
+		for _, header := range headerNames {
+			for _, name := range header.names {
+				if name == suffix {
+					return header.piiType
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// ColumnSummary classifies a column of a table.
+type ColumnSummary struct {
+	Location string
+	Index    int     // Column number, counting from 1
+	Name     string  // Header of the column
+	Type     PIIType // PII the column holds; empty if it holds none
+	Reason   string  // Why the column holds Type, such as "87% of values are email addresses"
+	Values   int     // Non-empty values in the column
+	Matches  int     // Values with a finding of Type
+}
+
+// Table classifies the columns of a table by their headers and the findings
+// in their values.
+type Table struct {
+	location string
+	header   []string
+	values   []int
+	found    []map[string]int // Values with findings of each type, per column
+}
+
+// NewTable creates a table classifier; header may be nil for tables without
+// one.
+func NewTable(location string, header []string) *Table {
+	return &Table{location: location, header: header}
+}
+
+// Add records the result of scanning a value in the column with the given
+// index, counting from 0.
+func (t *Table) Add(column int, value string, result *ScanResult) {
+	for len(t.values) <= column {
+		t.values = append(t.values, 0)
+		t.found = append(t.found, make(map[string]int))
+	}
+	if strings.TrimSpace(value) == "" {
+		return
+	}
+
+	t.values[column]++
+	for piiType := range result.Summary {
+		t.found[column][piiType]++
+	}
+}
+
+// Columns classifies each column seen so far. A column is classified by the
+// PII found in most of its values, failing that by its header.
+func (t *Table) Columns() []ColumnSummary {
+	n := max(len(t.values), len(t.header))
+	columns := make([]ColumnSummary, 0, n)
+
+	for i := 0; i < n; i++ {
+		column := ColumnSummary{Location: t.location, Index: i + 1, Name: ColumnName(t.header, i)}
+		found := make(map[string]int)
+		if i < len(t.values) {
+			column.Values = t.values[i]
+			found = t.found[i]
+		}
+
+		best := ""
+		for _, piiType := range sortedKeys(found) {
+			if found[piiType] > found[best] {
+				best = piiType
+			}
+		}
+
+		if column.Values > 0 && float64(found[best]) >= columnShare*float64(column.Values) {
+			column.Type = PIIType(best)
+			column.Matches = found[best]
+			column.Reason = percent(column.Matches, column.Values) + " of values are " + typeNoun(column.Type)
+		} else if piiType := headerType(column.Name); piiType != "" {
+			column.Type = piiType
+			column.Matches = found[string(piiType)]
+			column.Reason = "header names " + typeNoun(piiType)
+			if column.Matches > 0 {
+				column.Reason += "; " + percent(column.Matches, column.Values) + " of values match"
+			}
+		}
+
+		columns = append(columns, column)
+	}
+
+	return columns
+}
+
+// ColumnName returns the name of the column with the given index, counting
+// from 0: its header, or "column N" past the end of the header.
+func ColumnName(header []string, index int) string {
+	if index < len(header) && header[index] != "" {
+		return header[index]
+	}
+	return "column " + strconv.Itoa(index+1)
+}
+
+// typeNoun names the values of a PII type.
+func typeNoun(piiType PIIType) string {
+	if noun, exists := typeNouns[piiType]; exists {
+		return noun
+	}
+	return string(piiType) + " values"
+}
+
+// percent formats n/total as a whole percentage.
+func percent(n, total int) string {
+	return strconv.Itoa(n*100/total) + "%"
+}
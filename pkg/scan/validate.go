@@ -0,0 +1,246 @@
+package scan
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ValidationStatus is the outcome of checking a candidate match.
+type ValidationStatus string
+
+const (
+	ValidationNone   ValidationStatus = ""       // No validator applies
+	ValidationPassed ValidationStatus = "PASSED" // Structure or checksum is valid
+	ValidationFailed ValidationStatus = "FAILED" // Candidate is unlikely to be real PII
+)
+
+// Validation records the result of a structural or checksum check.
+type Validation struct {
+	Status ValidationStatus
+	Reason string  // Why the candidate passed or failed
+	Reject bool    // Discard the candidate instead of reporting it
+	Factor float64 // Confidence multiplier for candidates that are kept
+}
+
+// Validator checks a candidate match and reports whether it is plausible.
+type Validator func(value string) Validation
+
+// passed returns a successful validation.
+func passed(reason string) Validation {
+	return Validation{Status: ValidationPassed, Reason: reason, Factor: 1.0}
+}
+
+// rejected returns a validation that discards the candidate.
+func rejected(reason string) Validation {
+	return Validation{Status: ValidationFailed, Reason: reason, Reject: true}
+}
+
+// downgraded returns a failed validation that keeps the candidate with reduced confidence.
+func downgraded(reason string, factor float64) Validation {
+	return Validation{Status: ValidationFailed, Reason: reason, Factor: factor}
+}
+
+// digitsOf returns the ASCII digits in s, dropping separators.
+func digitsOf(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// luhnValid reports whether a digit string passes the Luhn checksum.
+func luhnValid(digits string) bool {
+	if len(digits) < 2 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// ValidateLuhn validates card-like numbers with the Luhn checksum.
+func ValidateLuhn(value string) Validation {
+	if luhnValid(digitsOf(value)) {
+		return passed("Luhn checksum valid")
+	}
+	return rejected("Luhn checksum failed")
+}
+
+// sampleSSNs are numbers published in advertising and documentation.
+var sampleSSNs = map[string]bool{
+	"078051120": true,
+	"219099999": true,
+	"123456789": true,
+}
+
+// ValidateSSN applies the SSA's structural rules to a US Social Security Number.
+func ValidateSSN(value string) Validation {
+	digits := digitsOf(value)
+	if len(digits) != 9 {
+		return rejected("SSN must have 9 digits")
+	}
+
+	area, group, serial := digits[:3], digits[3:5], digits[5:]
+	switch {
+	case area == "000":
+		return rejected("SSN area number 000 is never assigned")
+	case area == "666":
+		return rejected("SSN area number 666 is never assigned")
+	case area[0] == '9':
+		return rejected("SSN area numbers 900-999 are not assigned")
+	case group == "00":
+		return rejected("SSN group number 00 is never assigned")
+	case serial == "0000":
+		return rejected("SSN serial number 0000 is never assigned")
+	case sampleSSNs[digits]:
+		return downgraded("well-known sample SSN", 0.3)
+	}
+
+	return passed("SSN structure valid")
+}
+
+// ipRange is an IANA special-purpose IPv4 block.
+type ipRange struct {
+	prefix uint32
+	bits   int
+	name   string
+}
+
+// Special-purpose IPv4 blocks (RFC 6890 and related registrations).
+var (
+	ipUnroutable = []ipRange{
+		{0x00000000, 8, "\"this network\" (0.0.0.0/8)"},
+		{0x7f000000, 8, "loopback (127.0.0.0/8)"},
+		{0xe0000000, 4, "multicast (224.0.0.0/4)"},
+		{0xf0000000, 4, "reserved (240.0.0.0/4)"},
+	}
+	ipExample = []ipRange{
+		{0xc0000200, 24, "documentation (192.0.2.0/24)"},
+		{0xc6336400, 24, "documentation (198.51.100.0/24)"},
+		{0xcb007100, 24, "documentation (203.0.113.0/24)"},
+		{0xc6120000, 15, "benchmarking (198.18.0.0/15)"},
+	}
+	ipInternal = []ipRange{
+		{0x0a000000, 8, "private (10.0.0.0/8)"},
+		{0xac100000, 12, "private (172.16.0.0/12)"},
+		{0xc0a80000, 16, "private (192.168.0.0/16)"},
+		{0x64400000, 10, "shared address space (100.64.0.0/10)"},
+		{0xa9fe0000, 16, "link-local (169.254.0.0/16)"},
+	}
+)
+
+// classify returns the name of the first range containing ip.
+func classify(ip uint32, ranges []ipRange) (string, bool) {
+	for _, r := range ranges {
+		if ip>>(32-r.bits) == r.prefix>>(32-r.bits) {
+			return r.name, true
+		}
+	}
+	return "", false
+}
+
+// ValidateIPv4 classifies an IPv4 address against IANA special-purpose
+// ranges. Only public unicast addresses can identify an individual's
+// connection, so other ranges are rejected or down-scored.
+func ValidateIPv4(value string) Validation {
+	octets := strings.Split(value, ".")
+	if len(octets) != 4 {
+		return rejected("not a dotted-quad address")
+	}
+
+	var ip uint32
+	for _, octet := range octets {
+		if len(octet) > 1 && octet[0] == '0' {
+			return downgraded("leading zeros suggest a version number or OID", 0.3)
+		}
+		n, err := strconv.Atoi(octet)
+		if err != nil || n > 255 {
+			return rejected("octet out of range")
+		}
+		ip = ip<<8 | uint32(n)
+	}
+
+	if ip == 0xffffffff {
+		return rejected("limited broadcast address")
+	}
+	if name, ok := classify(ip, ipUnroutable); ok {
+		return rejected(name + " address")
+	}
+	if name, ok := classify(ip, ipExample); ok {
+		return downgraded(name+" address", 0.3)
+	}
+	if name, ok := classify(ip, ipInternal); ok {
+		return downgraded(name+" address", 0.6)
+	}
+
+	return passed("public unicast address")
+}
+
+// fileExtensions are suffixes that make an address-like string a file name,
+// such as "icon@2x.png".
+var fileExtensions = map[string]bool{
+	"png": true, "jpg": true, "jpeg": true, "gif": true, "svg": true, "webp": true,
+	"bmp": true, "ico": true, "js": true, "mjs": true, "ts": true, "css": true,
+	"scss": true, "html": true, "htm": true, "json": true, "xml": true, "yaml": true,
+	"yml": true, "txt": true, "md": true, "go": true, "py": true, "rb": true,
+	"java": true, "map": true, "woff": true, "woff2": true, "ttf": true, "pdf": true,
+}
+
+// ValidateEmail checks that an email address ends in a known top-level domain.
+func ValidateEmail(value string) Validation {
+	at := strings.LastIndexByte(value, '@')
+	dot := strings.LastIndexByte(value, '.')
+	if at < 0 || dot < at {
+		return rejected("missing domain")
+	}
+
+	tld := strings.ToLower(value[dot+1:])
+	switch {
+	case fileExtensions[tld] && !knownTLDs[tld]:
+		return rejected("." + tld + " is a file extension, not a top-level domain")
+	case knownTLDs[tld]:
+		return passed("known top-level domain ." + tld)
+	}
+
+	return downgraded("unrecognised top-level domain ."+tld, 0.5)
+}
+
+// knownTLDs holds generic and country-code top-level domains.
+var knownTLDs = func() map[string]bool {
+	tlds := make(map[string]bool)
+	generic := "com net org edu gov mil int info biz name pro aero coop museum mobi asia tel travel jobs cat post " +
+		"app dev page cloud online site tech store shop blog xyz top club email global live news agency digital " +
+		"solutions company services systems network media group world life today technology software academy " +
+		"center zone space website link click help guru studio design art support team work io ai co me tv " +
+		"health care clinic hospital bank finance money insurance law legal consulting partners ventures capital " +
+		"energy education school university college science city london berlin paris nyc tokyo amsterdam " +
+		"mail one plus run fun pub bar cafe host press social chat video photo photos music games " +
+		"zip mov"
+	countries := "ac ad ae af ag ai al am ao aq ar as at au aw ax az ba bb bd be bf bg bh bi bj bm bn bo br bs bt bw " +
+		"by bz ca cc cd cf cg ch ci ck cl cm cn co cr cu cv cw cx cy cz de dj dk dm do dz ec ee eg er es et eu fi " +
+		"fj fk fm fo fr ga gd ge gf gg gh gi gl gm gn gp gq gr gs gt gu gw gy hk hm hn hr ht hu id ie il im in io " +
+		"iq ir is it je jm jo jp ke kg kh ki km kn kp kr kw ky kz la lb lc li lk lr ls lt lu lv ly ma mc md me mg " +
+		"mh mk ml mm mn mo mp mq mr ms mt mu mv mw mx my mz na nc ne nf ng ni nl no np nr nu nz om pa pe pf pg ph " +
+		"pk pl pm pn pr ps pt pw py qa re ro rs ru rw sa sb sc sd se sg sh si sk sl sm sn so sr ss st su sv sx sy " +
+		"sz tc td tf tg th tj tk tl tm tn to tr tt tv tw tz ua ug uk us uy uz va vc ve vg vi vn vu wf ws ye yt za " +
+		"zm zw"
+	for _, tld := range strings.Fields(generic + " " + countries) {
+		tlds[tld] = true
+	}
+	return tlds
+}()
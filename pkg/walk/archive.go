@@ -0,0 +1,326 @@
+package walk
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/ulikunitz/xz"
+)
+
+// Default limits on opening archives.
+const (
+	DefaultArchiveDepth   = 3
+	DefaultArchiveSize    = 1 << 30
+	DefaultArchiveEntries = 10000
+)
+
+// ErrArchiveLimit is returned when an archive exceeds the size or entry
+// limits set in Options.
+var ErrArchiveLimit = errors.New("archive limit exceeded")
+
+// archiveBudget holds what is left of the limits of a top-level archive,
+// shared by the archives nested in it.
+type archiveBudget struct {
+	size    int64 // Uncompressed bytes that may still be read
+	entries int   // Entries that may still be visited
+}
+
+// IsArchive reports whether WalkArchive opens file: a zip or tar archive, or
+// a gzip, bzip2 or xz compressed file, nested in no more archives than the
+// depth limit allows.
+func (w *Walker) IsArchive(file File) bool {
+	switch file.Type {
+	case TypeZip, TypeTar, TypeGzip, TypeBzip2, TypeXZ:
+		return file.Depth < w.options.ArchiveDepth
+	}
+	return false
+}
+
+// WalkArchive calls fn with each file in an archive read from r, and a
+// reader of its content. Files are named by the archive's path, "!/" and
+// their path in the archive, as in "backup.tar.gz!/db/users.csv", and are
+// selected by the walker's options as if the archive were a directory. A
+// compressed tar archive is read as one archive; any other compressed file
+// holds a single file, named after it without its extension.
+//
+// Nested archives are passed to fn like other files, and may be opened with
+// WalkArchive in turn. An archive and all those nested in it share the size
+// and entry limits; once either is exceeded, WalkArchive stops and returns an
+// error wrapping ErrArchiveLimit. Zip archives are read in place if r is an
+// io.ReaderAt of the archive's Size, and otherwise loaded into memory.
+// Walking stops at the first error returned by fn.
+func (w *Walker) WalkArchive(archive File, r io.Reader, fn func(File, io.Reader) error) error {
+	if archive.budget == nil {
+		archive.budget = &archiveBudget{size: w.options.ArchiveSize, entries: w.options.ArchiveEntries}
+	}
+	a := &archiveWalk{walker: w, archive: archive, fn: fn}
+
+	var err error
+	switch archive.Type {
+	case TypeZip:
+		err = a.walkZip(r)
+	case TypeTar:
+		err = a.walkTar(r, false)
+	case TypeGzip, TypeBzip2, TypeXZ:
+		err = a.walkCompressed(r)
+	default:
+		err = fmt.Errorf("%s: not an archive", archive.Path)
+	}
+
+	// A read cut short by the size limit is reported as such, whoever saw it
+	if archive.budget.size < 0 {
+		return fmt.Errorf("%s: %w: more than %d bytes uncompressed", archive.Path, ErrArchiveLimit, w.options.ArchiveSize)
+	}
+	return err
+}
+
+// archiveWalk visits the entries of one archive.
+type archiveWalk struct {
+	walker  *Walker
+	archive File
+	fn      func(File, io.Reader) error
+}
+
+// walkZip visits the files in a zip archive.
+func (a *archiveWalk) walkZip(r io.Reader) error {
+	ra, ok := r.(io.ReaderAt)
+	size := a.archive.Size
+	if !ok || size < 0 {
+		data, err := io.ReadAll(a.limit(r))
+		if err != nil {
+			return err
+		}
+		ra, size = bytes.NewReader(data), int64(len(data))
+	}
+
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return fmt.Errorf("%s: %w", a.archive.Path, err)
+	}
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			if err := a.count(); err != nil {
+				return err
+			}
+			if !f.Mode().IsDir() {
+				a.walker.skip(a.entryPath(f.Name), SkipIrregular)
+			}
+			continue
+		}
+
+		err := a.visit(f.Name, int64(f.UncompressedSize64), func() (io.ReadCloser, error) {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			return struct {
+				io.Reader
+				io.Closer
+			}{a.limit(rc), rc}, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkTar visits the files in a tar archive. The bytes read from a tar
+// archive in a compressed file are counted as they are decompressed, rather
+// than entry by entry.
+func (a *archiveWalk) walkTar(r io.Reader, counted bool) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", a.archive.Path, err)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+		case tar.TypeDir:
+			if err := a.count(); err != nil {
+				return err
+			}
+			continue
+		default:
+			if err := a.count(); err != nil {
+				return err
+			}
+			a.walker.skip(a.entryPath(header.Name), SkipIrregular)
+			continue
+		}
+
+		err = a.visit(header.Name, header.Size, func() (io.ReadCloser, error) {
+			if counted {
+				return io.NopCloser(tr), nil
+			}
+			return io.NopCloser(a.limit(tr)), nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// walkCompressed visits the tar archive or single file in a compressed
+// file.
+func (a *archiveWalk) walkCompressed(r io.Reader) error {
+	name := strings.TrimSuffix(path.Base(a.archive.Path), path.Ext(a.archive.Path))
+
+	var decompressed io.Reader
+	switch a.archive.Type {
+	case TypeGzip:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("%s: %w", a.archive.Path, err)
+		}
+		if gr.Name != "" {
+			name = path.Base(gr.Name)
+		}
+		decompressed = gr
+	case TypeBzip2:
+		decompressed = bzip2.NewReader(r)
+	case TypeXZ:
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("%s: %w", a.archive.Path, err)
+		}
+		decompressed = xr
+	}
+
+	br := bufio.NewReaderSize(a.limit(decompressed), SniffLen)
+	head, err := br.Peek(SniffLen)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return fmt.Errorf("%s: %w", a.archive.Path, err)
+	}
+	if Sniff(head).Type == TypeTar {
+		return a.walkTar(br, true)
+	}
+
+	return a.visit(name, -1, func() (io.ReadCloser, error) {
+		return io.NopCloser(br), nil
+	})
+}
+
+// visit applies the walker's filters to an entry named name in the archive
+// and, if it is selected, calls fn with it.
+func (a *archiveWalk) visit(name string, size int64, open func() (io.ReadCloser, error)) error {
+	if err := a.count(); err != nil {
+		return err
+	}
+
+	// Entries are matched like files below a root directory
+	relPath := strings.TrimPrefix(path.Clean("/"+name), "/")
+	fullPath := a.entryPath(relPath)
+	options := a.walker.options
+
+	if !options.IncludeHidden && hiddenPath(relPath) {
+		a.walker.skip(fullPath, SkipHidden)
+		return nil
+	}
+	if excludedPath(options.Exclude, relPath) {
+		a.walker.skip(fullPath, SkipExcluded)
+		return nil
+	}
+	if len(options.Include) > 0 && !matchAny(options.Include, relPath) {
+		a.walker.skip(fullPath, SkipIncluded)
+		return nil
+	}
+	if options.MaxFileSize > 0 && size > options.MaxFileSize {
+		a.walker.skip(fullPath, SkipTooLarge)
+		return nil
+	}
+
+	rc, err := open()
+	if err != nil {
+		// Such as an encrypted entry or an unsupported compression method
+		a.walker.skip(fullPath, SkipUnreadable)
+		return nil
+	}
+	defer rc.Close()
+
+	br := bufio.NewReaderSize(rc, SniffLen)
+	head, _ := br.Peek(SniffLen)
+	file := File{
+		Path:   fullPath,
+		Size:   size,
+		Format: Sniff(head),
+		Depth:  a.archive.Depth + 1,
+		budget: a.archive.budget,
+	}
+	return a.fn(file, br)
+}
+
+// count counts an entry against the entry limit.
+func (a *archiveWalk) count() error {
+	budget := a.archive.budget
+	if budget.entries--; budget.entries < 0 {
+		return fmt.Errorf("%s: %w: more than %d entries", a.archive.Path, ErrArchiveLimit, a.walker.options.ArchiveEntries)
+	}
+	return nil
+}
+
+// limit counts the bytes read from r against the size limit.
+func (a *archiveWalk) limit(r io.Reader) io.Reader {
+	return &budgetReader{r: r, budget: a.archive.budget}
+}
+
+// entryPath returns the path of an entry of the archive.
+func (a *archiveWalk) entryPath(name string) string {
+	return a.archive.Path + "!/" + strings.TrimPrefix(name, "/")
+}
+
+// budgetReader fails once more bytes have been read than the budget allows.
+type budgetReader struct {
+	r      io.Reader
+	budget *archiveBudget
+}
+
+func (b *budgetReader) Read(p []byte) (int, error) {
+	if b.budget.size < 0 {
+		return 0, ErrArchiveLimit
+	}
+	if int64(len(p)) > b.budget.size+1 {
+		p = p[:b.budget.size+1]
+	}
+	n, err := b.r.Read(p)
+	b.budget.size -= int64(n)
+	if b.budget.size < 0 {
+		return n, ErrArchiveLimit
+	}
+	return n, err
+}
+
+// excludedPath reports whether a slash-separated path, or any directory it
+// is in, matches the exclude patterns.
+func excludedPath(patterns []string, relPath string) bool {
+	for dir := relPath; dir != "."; dir = path.Dir(dir) {
+		if matchAny(patterns, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// hiddenPath reports whether any segment of a slash-separated path is a
+// dot-file or dot-directory.
+func hiddenPath(relPath string) bool {
+	for _, segment := range strings.Split(relPath, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return true
+		}
+	}
+	return false
+}
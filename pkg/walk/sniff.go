@@ -0,0 +1,283 @@
+package walk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// SniffLen is the number of bytes at the start of a file that Sniff looks at.
+const SniffLen = 8 << 10
+
+// File types reported by Sniff. Known binary formats are reported by name,
+// such as "pdf" or "zip"; every type other than TypeText is binary.
+const (
+	TypeText   = "text"
+	TypeBinary = "binary"
+	TypePDF    = "pdf"
+	TypeZip    = "zip"
+	TypeTar    = "tar"
+	TypeGzip   = "gzip"
+	TypeBzip2  = "bzip2"
+	TypeXZ     = "xz"
+)
+
+// Text encodings reported by Sniff.
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+	EncodingLatin1  = "latin-1"
+)
+
+// Format describes the content of a file.
+type Format struct {
+	Type     string // TypeText, TypeBinary or the name of a binary format
+	Encoding string // Encoding of a text file
+	BOM      bool   // Text starts with a byte order mark
+}
+
+// magics identifies binary formats by the bytes at a fixed offset.
+var magics = []struct {
+	offset int
+	magic  string
+	name   string
+}{
+	{0, "%PDF-", TypePDF},
+	{0, "PK\x03\x04", TypeZip},
+	{0, "PK\x05\x06", TypeZip},
+	{0, "\x1f\x8b", TypeGzip},
+	{0, "BZh", TypeBzip2},
+	{0, "\xfd7zXZ\x00", TypeXZ},
+	{257, "ustar", TypeTar},
+	{0, "\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1", "ole"},
+	{0, "SQLite format 3\x00", "sqlite"},
+	{0, "\x89PNG\r\n\x1a\n", "png"},
+	{0, "\xff\xd8\xff", "jpeg"},
+	{0, "GIF8", "gif"},
+	{0, "\x7fELF", "elf"},
+}
+
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+// Sniff guesses the format of a file from its first bytes, up to SniffLen of
+// them. Text is told apart from binary data by byte order marks, magic
+// numbers, NUL bytes and the share of control characters; text that is not
+// valid UTF-8 is taken to be Latin-1.
+func Sniff(head []byte) Format {
+	switch {
+	case bytes.HasPrefix(head, bomUTF8):
+		return Format{Type: TypeText, Encoding: EncodingUTF8, BOM: true}
+	case bytes.HasPrefix(head, bomUTF16LE):
+		return Format{Type: TypeText, Encoding: EncodingUTF16LE, BOM: true}
+	case bytes.HasPrefix(head, bomUTF16BE):
+		return Format{Type: TypeText, Encoding: EncodingUTF16BE, BOM: true}
+	}
+
+	for _, m := range magics {
+		if len(head) >= m.offset+len(m.magic) && string(head[m.offset:m.offset+len(m.magic)]) == m.magic {
+			return Format{Type: m.name}
+		}
+	}
+
+	if encoding := sniffUTF16(head); encoding != "" {
+		return Format{Type: TypeText, Encoding: encoding}
+	}
+
+	controls := 0
+	for _, c := range head {
+		switch {
+		case c == 0:
+			return Format{Type: TypeBinary}
+		case c < 0x20 && c != '\t' && c != '\n' && c != '\r' && c != '\f' && c != '\v' && c != '\b' && c != 0x1b:
+			controls++
+		}
+	}
+	if controls*10 > len(head) {
+		return Format{Type: TypeBinary}
+	}
+
+	if !utf8.Valid(trimPartialRune(head)) {
+		return Format{Type: TypeText, Encoding: EncodingLatin1}
+	}
+	return Format{Type: TypeText, Encoding: EncodingUTF8}
+}
+
+// SniffFile sniffs the format of the file at path.
+func SniffFile(path string) (Format, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Format{}, err
+	}
+	defer f.Close()
+
+	head := make([]byte, SniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return Format{}, err
+	}
+	return Sniff(head[:n]), nil
+}
+
+// sniffUTF16 recognizes UTF-16 without a byte order mark by the NUL high
+// bytes of ASCII characters, which fall on odd offsets in little-endian text
+// and even offsets in big-endian text.
+func sniffUTF16(head []byte) string {
+	units := len(head) / 2
+	if units < 2 {
+		return ""
+	}
+
+	even, odd := 0, 0
+	for i := 0; i+1 < len(head); i += 2 {
+		if head[i] == 0 {
+			even++
+		}
+		if head[i+1] == 0 {
+			odd++
+		}
+	}
+
+	switch {
+	case odd*2 >= units && even*10 < units:
+		return EncodingUTF16LE
+	case even*2 >= units && odd*10 < units:
+		return EncodingUTF16BE
+	}
+	return ""
+}
+
+// trimPartialRune drops an incomplete UTF-8 sequence cut off at the end of
+// head.
+func trimPartialRune(head []byte) []byte {
+	for i := len(head) - 1; i >= 0 && i >= len(head)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(head[i]) {
+			if !utf8.FullRune(head[i:]) {
+				return head[:i]
+			}
+			break
+		}
+	}
+	return head
+}
+
+// NewTextReader returns a reader of the text in r as UTF-8 without a byte
+// order mark, transcoding it from the encoding given by format.
+func NewTextReader(r io.Reader, format Format) io.Reader {
+	t := &textReader{r: r, in: make([]byte, 0, 32<<10)}
+
+	switch format.Encoding {
+	case EncodingUTF16LE:
+		t.decode = decodeUTF16(binary.LittleEndian)
+	case EncodingUTF16BE:
+		t.decode = decodeUTF16(binary.BigEndian)
+	case EncodingLatin1:
+		t.decode = decodeLatin1
+	default:
+		if !format.BOM {
+			return r
+		}
+		t.decode = func(dst, src []byte, eof bool) ([]byte, int) {
+			return append(dst, src...), len(src)
+		}
+	}
+
+	if format.BOM {
+		t.skip = 2
+		if format.Encoding == EncodingUTF8 {
+			t.skip = len(bomUTF8)
+		}
+	}
+	return t
+}
+
+// textReader transcodes a stream to UTF-8.
+type textReader struct {
+	r    io.Reader
+	skip int // Bytes of byte order mark still to drop
+
+	// decode appends the UTF-8 encoding of src to dst and returns the number
+	// of bytes of src consumed. Unless eof is set, it may leave an incomplete
+	// character for the next call.
+	decode func(dst, src []byte, eof bool) ([]byte, int)
+
+	in  []byte // Input not yet decoded
+	buf []byte // Backing array of out
+	out []byte // Decoded output not yet returned
+	err error
+}
+
+func (t *textReader) Read(p []byte) (int, error) {
+	for len(t.out) == 0 {
+		if t.err != nil {
+			return 0, t.err
+		}
+
+		n, err := t.r.Read(t.in[len(t.in):cap(t.in)])
+		t.in = t.in[:len(t.in)+n]
+		if err != nil {
+			t.err = err
+		}
+
+		if t.skip > 0 {
+			k := min(t.skip, len(t.in))
+			t.in = t.in[:copy(t.in, t.in[k:])]
+			t.skip -= k
+		}
+
+		var consumed int
+		t.buf, consumed = t.decode(t.buf[:0], t.in, t.err != nil)
+		t.out = t.buf
+		t.in = t.in[:copy(t.in, t.in[consumed:])]
+	}
+
+	n := copy(p, t.out)
+	t.out = t.out[n:]
+	return n, nil
+}
+
+// decodeUTF16 returns a decoder of UTF-16 in the given byte order.
+func decodeUTF16(order binary.ByteOrder) func(dst, src []byte, eof bool) ([]byte, int) {
+	return func(dst, src []byte, eof bool) ([]byte, int) {
+		i := 0
+		for ; i+1 < len(src); i += 2 {
+			r := rune(order.Uint16(src[i:]))
+			if utf16.IsSurrogate(r) {
+				if i+3 >= len(src) {
+					if !eof {
+						break
+					}
+					r = utf8.RuneError
+				} else if pair := utf16.DecodeRune(r, rune(order.Uint16(src[i+2:]))); pair != utf8.RuneError {
+					r = pair
+					i += 2
+				} else {
+					r = utf8.RuneError
+				}
+			}
+			dst = utf8.AppendRune(dst, r)
+		}
+
+		// An odd trailing byte cannot be decoded
+		if eof && i < len(src) {
+			dst = utf8.AppendRune(dst, utf8.RuneError)
+			i = len(src)
+		}
+		return dst, i
+	}
+}
+
+// decodeLatin1 decodes ISO 8859-1, whose bytes are the first 256 code points.
+func decodeLatin1(dst, src []byte, eof bool) ([]byte, int) {
+	for _, c := range src {
+		dst = utf8.AppendRune(dst, rune(c))
+	}
+	return dst, len(src)
+}
@@ -0,0 +1,272 @@
+// Package walk provides filesystem traversal for privacy scanning.
+package walk
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Options controls which files a Walker visits.
+type Options struct {
+	Include        []string // Glob patterns a file must match; empty matches all files
+	Exclude        []string // Glob patterns for files and directories to skip
+	FollowSymlinks bool     // Follow symbolic links to files and directories
+	MaxFileSize    int64    // Skip files larger than this many bytes; 0 disables the limit
+	IncludeHidden  bool     // Visit dot-files and dot-directories
+
+	// Limits on opening archives with WalkArchive. ArchiveDepth is the number
+	// of levels of nested archives opened, defaulting to DefaultArchiveDepth;
+	// a negative depth opens none. ArchiveSize and ArchiveEntries bound the
+	// uncompressed bytes read and entries visited in a top-level archive and
+	// those nested in it, defaulting to DefaultArchiveSize and
+	// DefaultArchiveEntries.
+	ArchiveDepth   int
+	ArchiveSize    int64
+	ArchiveEntries int
+
+	// OnSkip, when set, is called for every path the walker decides not to visit.
+	OnSkip func(path, reason string)
+}
+
+// File describes a file selected for scanning.
+type File struct {
+	Path   string
+	Size   int64 // -1 if unknown, as for the content of a compressed file
+	Format       // Sniffed from the start of the file; empty if it could not be read
+	Depth  int   // Number of archives the file is in
+
+	budget *archiveBudget // Limits left in the top-level archive the file is in
+}
+
+// Skip reasons reported through Options.OnSkip.
+const (
+	SkipHidden        = "hidden"
+	SkipExcluded      = "excluded"
+	SkipIncluded      = "not included"
+	SkipSymlink       = "symlink"
+	SkipTooLarge      = "too large"
+	SkipLoop          = "symlink loop"
+	SkipIrregular     = "not a regular file"
+	SkipUnreadable    = "unreadable archive entry"
+	SkipUnreadableDir = "unreadable directory"
+	SkipRemoved       = "removed while walking"
+)
+
+// Walker recursively enumerates files below a root path.
+type Walker struct {
+	options Options
+}
+
+// NewWalker creates a new filesystem walker.
+func NewWalker(options Options) *Walker {
+	if options.ArchiveDepth == 0 {
+		options.ArchiveDepth = DefaultArchiveDepth
+	}
+	if options.ArchiveSize <= 0 {
+		options.ArchiveSize = DefaultArchiveSize
+	}
+	if options.ArchiveEntries <= 0 {
+		options.ArchiveEntries = DefaultArchiveEntries
+	}
+	return &Walker{options: options}
+}
+
+// Walk visits every selected file below root, calling fn for each one.
+// Root may be a single file, in which case only that file is considered.
+// Walking stops at the first error returned by fn.
+func (w *Walker) Walk(root string, fn func(File) error) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return w.visitFile(root, filepath.Base(root), info, fn)
+	}
+
+	visited := make(map[string]bool)
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		visited[real] = true
+	}
+
+	return w.walkDir(root, "", visited, fn)
+}
+
+// walkDir walks a directory; rel is its slash-separated path relative to the root.
+// Only the root failing to be read is an error; directories below it that
+// cannot be read are skipped.
+func (w *Walker) walkDir(dir, rel string, visited map[string]bool, fn func(File) error) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if rel == "" {
+			return err
+		}
+		w.skip(dir, SkipUnreadableDir)
+		return nil
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		fullPath := filepath.Join(dir, name)
+		relPath := path.Join(rel, name)
+
+		if !w.options.IncludeHidden && strings.HasPrefix(name, ".") {
+			w.skip(fullPath, SkipHidden)
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// The entry was removed since the directory was read
+			w.skip(fullPath, SkipRemoved)
+			continue
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			if !w.options.FollowSymlinks {
+				w.skip(fullPath, SkipSymlink)
+				continue
+			}
+			info, err = os.Stat(fullPath)
+			if err != nil {
+				// Dangling links are not an error for the walk as a whole
+				w.skip(fullPath, SkipSymlink)
+				continue
+			}
+		}
+
+		if info.IsDir() {
+			if matchAny(w.options.Exclude, relPath) {
+				w.skip(fullPath, SkipExcluded)
+				continue
+			}
+
+			real, err := filepath.EvalSymlinks(fullPath)
+			if err != nil {
+				w.skip(fullPath, SkipUnreadableDir)
+				continue
+			}
+			if visited[real] {
+				w.skip(fullPath, SkipLoop)
+				continue
+			}
+			visited[real] = true
+
+			if err := w.walkDir(fullPath, relPath, visited, fn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := w.visitFile(fullPath, relPath, info, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// visitFile applies file-level filters and calls fn if the file is selected.
+func (w *Walker) visitFile(fullPath, relPath string, info os.FileInfo, fn func(File) error) error {
+	if !info.Mode().IsRegular() {
+		w.skip(fullPath, SkipIrregular)
+		return nil
+	}
+
+	if matchAny(w.options.Exclude, relPath) {
+		w.skip(fullPath, SkipExcluded)
+		return nil
+	}
+
+	if len(w.options.Include) > 0 && !matchAny(w.options.Include, relPath) {
+		w.skip(fullPath, SkipIncluded)
+		return nil
+	}
+
+	if w.options.MaxFileSize > 0 && info.Size() > w.options.MaxFileSize {
+		w.skip(fullPath, SkipTooLarge)
+		return nil
+	}
+
+	// A file that cannot be read is still passed on, so that the error is
+	// reported when it is scanned
+	format, _ := SniffFile(fullPath)
+
+	return fn(File{Path: fullPath, Size: info.Size(), Format: format})
+}
+
+// skip reports a skipped path.
+func (w *Walker) skip(path, reason string) {
+	if w.options.OnSkip != nil {
+		w.options.OnSkip(path, reason)
+	}
+}
+
+// ValidatePatterns checks that all glob patterns are well formed.
+func ValidatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		for _, segment := range strings.Split(pattern, "/") {
+			if segment == "**" {
+				continue
+			}
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// matchAny reports whether relPath matches any of the patterns.
+func matchAny(patterns []string, relPath string) bool {
+	for _, pattern := range patterns {
+		if MatchGlob(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchGlob matches a slash-separated relative path against a glob pattern.
+// Patterns without a slash match the base name at any depth, like .gitignore
+// entries; "**" matches any number of path segments.
+func MatchGlob(pattern, relPath string) bool {
+	pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+	relPath = filepath.ToSlash(relPath)
+
+	if !strings.Contains(pattern, "/") {
+		matched, _ := path.Match(pattern, path.Base(relPath))
+		return matched
+	}
+
+	return matchSegments(strings.Split(strings.TrimSuffix(pattern, "/"), "/"), strings.Split(relPath, "/"))
+}
+
+// matchSegments matches path segments, expanding "**" wildcards.
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], segments[0]); !matched {
+			return false
+		}
+
+		pattern = pattern[1:]
+		segments = segments[1:]
+	}
+
+	return len(segments) == 0
+}
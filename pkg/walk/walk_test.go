@@ -0,0 +1,335 @@
+package walk
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/tool/main.go", true},
+		{"*.go", "main.txt", false},
+		{"vendor/**", "vendor/lib/a.go", true},
+		{"vendor/**", "vendor", true},
+		{"**/testdata/**", "pkg/scan/testdata/x.txt", true},
+		{"**/testdata/**", "testdata/x.txt", true},
+		{"pkg/*.go", "pkg/scan/a.go", false},
+	}
+
+	for _, tt := range tests {
+		if got := MatchGlob(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"a.txt":         "a",
+		"big.txt":       "0123456789",
+		"sub/b.go":      "b",
+		"vendor/c.go":   "c",
+		".hidden/d.txt": "d",
+		"sub/.env":      "e",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	walker := NewWalker(Options{Exclude: []string{"vendor"}, MaxFileSize: 5})
+	var got []string
+	err := walker.Walk(root, func(f File) error {
+		rel, _ := filepath.Rel(root, f.Path)
+		got = append(got, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(got)
+	want := []string{"a.txt", "sub/b.go"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Walk visited %v, want %v", got, want)
+	}
+}
+
+func TestWalkUnreadableDir(t *testing.T) {
+	root := t.TempDir()
+	locked := filepath.Join(root, "locked")
+	for _, dir := range []string{locked, filepath.Join(root, "open")} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(locked, 0o000); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(locked, 0o755)
+	if _, err := os.ReadDir(locked); err == nil {
+		t.Skip("directory permissions are not enforced")
+	}
+
+	var got, skipped []string
+	walker := NewWalker(Options{
+		OnSkip: func(path, reason string) { skipped = append(skipped, path+" "+reason) },
+	})
+	err := walker.Walk(root, func(f File) error {
+		rel, _ := filepath.Rel(root, f.Path)
+		got = append(got, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 1 || got[0] != "open/a.txt" {
+		t.Errorf("Walk visited %v, want [open/a.txt]", got)
+	}
+	if want := locked + " " + SkipUnreadableDir; len(skipped) != 1 || skipped[0] != want {
+		t.Errorf("Walk skipped %q, want [%q]", skipped, want)
+	}
+
+	// The root itself must be readable
+	if err := NewWalker(Options{}).Walk(locked, func(File) error { return nil }); err == nil {
+		t.Error("Walk of an unreadable root succeeded")
+	}
+}
+
+func TestSniff(t *testing.T) {
+	utf16le := []byte{'n', 0, 'a', 0, 'm', 0, 'e', 0, '=', 0, 0xe9, 0}
+	tests := []struct {
+		name string
+		head []byte
+		want Format
+	}{
+		{"ascii", []byte("email: a@example.com\n"), Format{Type: TypeText, Encoding: EncodingUTF8}},
+		{"utf-8", []byte("naïve café\n"), Format{Type: TypeText, Encoding: EncodingUTF8}},
+		{"utf-8 cut mid-rune", []byte("caf\xc3"), Format{Type: TypeText, Encoding: EncodingUTF8}},
+		{"utf-8 bom", []byte("\xef\xbb\xbfname"), Format{Type: TypeText, Encoding: EncodingUTF8, BOM: true}},
+		{"utf-16le bom", append([]byte{0xff, 0xfe}, utf16le...), Format{Type: TypeText, Encoding: EncodingUTF16LE, BOM: true}},
+		{"utf-16be bom", []byte{0xfe, 0xff, 0, 'a', 0, 'b'}, Format{Type: TypeText, Encoding: EncodingUTF16BE, BOM: true}},
+		{"utf-16le", utf16le, Format{Type: TypeText, Encoding: EncodingUTF16LE}},
+		{"utf-16be", []byte{0, 'n', 0, 'a', 0, 'm', 0, 'e'}, Format{Type: TypeText, Encoding: EncodingUTF16BE}},
+		{"latin-1", []byte("nom: Andr\xe9 M\xfcller\n"), Format{Type: TypeText, Encoding: EncodingLatin1}},
+		{"pdf", []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n"), Format{Type: "pdf"}},
+		{"zip", []byte("PK\x03\x04\x14\x00"), Format{Type: "zip"}},
+		{"gzip", []byte{0x1f, 0x8b, 0x08, 0x00}, Format{Type: "gzip"}},
+		{"nul bytes", []byte("\x01\x02abc\x00\x00\x00def"), Format{Type: TypeBinary}},
+		{"control characters", []byte("\x01\x02\x03\x04\x05abc"), Format{Type: TypeBinary}},
+	}
+
+	for _, tt := range tests {
+		if got := Sniff(tt.head); got != tt.want {
+			t.Errorf("%s: Sniff = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewTextReader(t *testing.T) {
+	want := "José <jose@example.com> 😀\n"
+
+	encodeUTF16 := func(order binary.AppendByteOrder, bom bool) []byte {
+		var b []byte
+		if bom {
+			b = order.AppendUint16(b, 0xfeff)
+		}
+		for _, unit := range utf16.Encode([]rune(want)) {
+			b = order.AppendUint16(b, unit)
+		}
+		return b
+	}
+
+	tests := []struct {
+		name   string
+		input  []byte
+		format Format
+	}{
+		{"utf-8", []byte(want), Format{Type: TypeText, Encoding: EncodingUTF8}},
+		{"utf-8 bom", append([]byte("\xef\xbb\xbf"), want...), Format{Type: TypeText, Encoding: EncodingUTF8, BOM: true}},
+		{"utf-16le bom", encodeUTF16(binary.LittleEndian, true), Format{Type: TypeText, Encoding: EncodingUTF16LE, BOM: true}},
+		{"utf-16be", encodeUTF16(binary.BigEndian, false), Format{Type: TypeText, Encoding: EncodingUTF16BE}},
+		{"latin-1", []byte("Jos\xe9 <jose@example.com> "), Format{Type: TypeText, Encoding: EncodingLatin1}},
+	}
+
+	for _, tt := range tests {
+		// One byte at a time splits every character across reads
+		got, err := io.ReadAll(NewTextReader(iotest.OneByteReader(bytes.NewReader(tt.input)), tt.format))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		expected := want
+		if tt.format.Encoding == EncodingLatin1 {
+			expected = "José <jose@example.com> "
+		}
+		if string(got) != expected {
+			t.Errorf("%s: read %q, want %q", tt.name, got, expected)
+		}
+	}
+}
+
+// tarGz returns a gzip compressed tar archive of the given files, in order,
+// with a directory entry for the first.
+func tarGz(t *testing.T, files ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	if len(files) > 0 {
+		dir := filepath.ToSlash(filepath.Dir(files[0][0])) + "/"
+		if err := tw.WriteHeader(&tar.Header{Name: dir, Typeflag: tar.TypeDir, Mode: 0o755}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: f[0], Mode: 0o644, Size: int64(len(f[1]))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWalkArchive(t *testing.T) {
+	var inner bytes.Buffer
+	zw := zip.NewWriter(&inner)
+	for _, name := range []string{"notes.txt", "deeper.zip"} {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(f, "PK\x03\x04 not really")
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := tarGz(t,
+		[2]string{"db/users.csv", "email\nana@example.com\n"},
+		[2]string{"db/.secret", "hidden"},
+		[2]string{"vendor/lib.txt", "excluded"},
+		[2]string{"../escape.txt", "outside"},
+		[2]string{"nested.zip", inner.String()},
+	)
+
+	var skipped []string
+	walker := NewWalker(Options{
+		Exclude:      []string{"vendor"},
+		ArchiveDepth: 2,
+		OnSkip:       func(path, reason string) { skipped = append(skipped, path+" "+reason) },
+	})
+	archive := File{Path: "backup.tar.gz", Size: int64(len(data)), Format: Sniff(data)}
+	if !walker.IsArchive(archive) {
+		t.Fatalf("IsArchive(%+v) = false", archive)
+	}
+
+	var got []string
+	var walkFn func(File, io.Reader) error
+	walkFn = func(f File, r io.Reader) error {
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		got = append(got, fmt.Sprintf("%s %s %d %d", f.Path, f.Type, f.Size, f.Depth))
+		if walker.IsArchive(f) {
+			return walker.WalkArchive(f, bytes.NewReader(content), walkFn)
+		}
+		return nil
+	}
+	if err := walker.WalkArchive(archive, bytes.NewReader(data), walkFn); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"backup.tar.gz!/db/users.csv text 22 1",
+		"backup.tar.gz!/escape.txt text 7 1",
+		fmt.Sprintf("backup.tar.gz!/nested.zip zip %d 1", inner.Len()),
+		"backup.tar.gz!/nested.zip!/notes.txt zip 15 2",
+		"backup.tar.gz!/nested.zip!/deeper.zip zip 15 2",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("WalkArchive visited:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	wantSkipped := []string{"backup.tar.gz!/db/.secret hidden", "backup.tar.gz!/vendor/lib.txt excluded"}
+	if strings.Join(skipped, "|") != strings.Join(wantSkipped, "|") {
+		t.Errorf("WalkArchive skipped %q, want %q", skipped, wantSkipped)
+	}
+
+	// Archives nested beyond the depth limit are not opened
+	if walker.IsArchive(File{Format: Format{Type: TypeZip}, Depth: 2}) {
+		t.Errorf("IsArchive opens archives beyond the depth limit")
+	}
+	if NewWalker(Options{ArchiveDepth: -1}).IsArchive(archive) {
+		t.Errorf("IsArchive opens archives with a negative depth limit")
+	}
+}
+
+func TestWalkArchiveLimits(t *testing.T) {
+	data := tarGz(t,
+		[2]string{"logs/a.log", strings.Repeat("a", 4000)},
+		[2]string{"logs/b.log", strings.Repeat("b", 4000)},
+		[2]string{"logs/c.log", strings.Repeat("c", 4000)},
+	)
+	archive := File{Path: "logs.tgz", Size: int64(len(data)), Format: Sniff(data)}
+
+	tests := []struct {
+		name    string
+		options Options
+		visited int
+	}{
+		{"entries", Options{ArchiveEntries: 3}, 2},
+		{"size", Options{ArchiveSize: 10000}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			visited := 0
+			err := NewWalker(tt.options).WalkArchive(archive, bytes.NewReader(data), func(f File, r io.Reader) error {
+				visited++
+				_, err := io.Copy(io.Discard, r)
+				return err
+			})
+			if !errors.Is(err, ErrArchiveLimit) {
+				t.Errorf("WalkArchive returned %v, want ErrArchiveLimit", err)
+			}
+			if visited != tt.visited {
+				t.Errorf("WalkArchive visited %d files, want %d", visited, tt.visited)
+			}
+		})
+	}
+}